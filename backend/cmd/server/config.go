@@ -29,10 +29,20 @@ type ServerConfig struct {
 	debug bool
 
 	jwtSecret string
+
+	flavorDescriptorsFile string
+
+	// reportImageHosts are the hosts beer pictures in PDF reports may be
+	// fetched from. Without any, PDF reports have no pictures.
+	reportImageHosts []string
+
+	votingDeadlineInterval time.Duration
 }
 
 const httpDefaultTimeout time.Duration = 20 * time.Second
 
+const votingDeadlineDefaultInterval time.Duration = 15 * time.Second
+
 func parseBoolWithDefault(env string, d bool) bool {
 	debug, err := strconv.ParseBool(os.Getenv(env))
 	if err != nil {
@@ -51,6 +61,11 @@ func NewConfigFromEnv() (ServerConfig, error) {
 	corsAllowedOriginsString := os.Getenv("HTTP_CORS_ALLOWED_ORIGINS")
 	corsAllowedOrigins := strings.Split(corsAllowedOriginsString, ",")
 
+	reportImageHosts := []string{}
+	if hosts := os.Getenv("REPORT_IMAGE_HOSTS"); hosts != "" {
+		reportImageHosts = strings.Split(hosts, ",")
+	}
+
 	config := ServerConfig{
 		dbUser: os.Getenv("DB_USER"),
 		dbPass: os.Getenv("DB_PASS"),
@@ -72,6 +87,12 @@ func NewConfigFromEnv() (ServerConfig, error) {
 		debug: debug,
 
 		jwtSecret: os.Getenv("JWT_SECRET"),
+
+		flavorDescriptorsFile: os.Getenv("FLAVOR_DESCRIPTORS_FILE"),
+
+		reportImageHosts: reportImageHosts,
+
+		votingDeadlineInterval: votingDeadlineDefaultInterval,
 	}
 
 	return config, nil
@@ -12,8 +12,13 @@ import (
 
 	"skafteresort.se/beers/internal/auth"
 	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/flavors"
+	"skafteresort.se/beers/internal/guesses"
 	"skafteresort.se/beers/internal/providers"
+	"skafteresort.se/beers/internal/recommendations"
+	"skafteresort.se/beers/internal/results"
 	"skafteresort.se/beers/internal/rooms"
+	"skafteresort.se/beers/internal/stats"
 	"skafteresort.se/beers/internal/web"
 )
 
@@ -25,9 +30,14 @@ type Server struct {
 	config     ServerConfig
 	httpServer *http.Server
 
-	beerService *beers.BeerService
-	roomService *rooms.RoomService
-	userService *auth.UserService
+	beerService    *beers.BeerService
+	guessService   *guesses.GuessService
+	resultsService *results.ResultsService
+	statsService   *stats.StatsService
+
+	recommendationService *recommendations.RecommendationService
+	roomService           *rooms.RoomService
+	userService           *auth.UserService
 
 	// beerRepo
 }
@@ -80,10 +90,17 @@ func (s *Server) Start(ctx context.Context) {
 
 	centrifugoProvider := providers.NewCentrifugoProvider(gocentClient, s.logger)
 
+	taxonomy, err := flavors.LoadFile(s.config.flavorDescriptorsFile)
+	if err != nil {
+		s.logger.Error("Unable to load flavor descriptors", slog.String("error", err.Error()))
+		return
+	}
+
 	s.beerService = beers.NewBeerService(
 		beers.NewBeerRepo(s.db),
 		s.logger,
 		centrifugoProvider,
+		taxonomy,
 	)
 
 	s.roomService = rooms.NewRoomService(
@@ -92,11 +109,42 @@ func (s *Server) Start(ctx context.Context) {
 		centrifugoProvider,
 	)
 
+	s.guessService = guesses.NewGuessService(
+		guesses.NewGuessRepo(s.db),
+		s.logger,
+		centrifugoProvider,
+	)
+
+	s.resultsService = results.NewResultsService(
+		results.NewResultsRepo(s.db),
+		s.logger,
+	)
+
+	s.statsService = stats.NewStatsService(
+		stats.NewStatsRepo(s.db),
+		s.logger,
+	)
+
+	s.recommendationService = recommendations.NewRecommendationService(
+		recommendations.NewRecommendationRepo(s.db),
+		s.logger,
+	)
+
 	s.userService = auth.NewUserService(
 		auth.NewUserRepo(s.db),
 		s.logger,
 	)
 
+	go s.beerService.WatchVotingDeadlines(
+		ctx,
+		s.config.votingDeadlineInterval,
+		func(ctx context.Context, beerId int) {
+			if err := s.recommendationService.RefreshBeer(ctx, beerId); err != nil {
+				s.logger.Error("WatchVotingDeadlines/recommendations", "err", err)
+			}
+		},
+	)
+
 	s.serveHTTP()
 
 	<-ctx.Done()
@@ -112,6 +160,11 @@ func (s *Server) serveHTTP() {
 		s.userService,
 		s.roomService,
 		s.beerService,
+		s.guessService,
+		s.resultsService,
+		s.statsService,
+		s.recommendationService,
+		providers.NewImageProvider(s.config.reportImageHosts),
 	)
 	s.httpServer = &http.Server{
 		Addr:         s.config.httpEndpointPort,
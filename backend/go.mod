@@ -4,6 +4,7 @@ go 1.23.4
 
 require (
 	github.com/centrifugal/gocent/v3 v3.4.0
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/google/uuid v1.6.0
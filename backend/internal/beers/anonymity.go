@@ -0,0 +1,220 @@
+package beers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// Anonymity decides how participants are shown next to their ratings.
+type Anonymity string
+
+const (
+	AnonymityOff       Anonymity = "off"
+	AnonymityPseudonym Anonymity = "pseudonym"
+	AnonymityHidden    Anonymity = "hidden"
+)
+
+func (a Anonymity) Valid() bool {
+	return a == AnonymityOff || a == AnonymityPseudonym || a == AnonymityHidden
+}
+
+// Identity returns the user id and name others get to see next to a rating.
+// Anonymous rooms never expose the user id.
+func (a Anonymity) Identity(names Pseudonyms, userId int, name string) (int, string) {
+	switch a {
+	case AnonymityPseudonym:
+		return 0, names.Name(userId)
+	case AnonymityHidden:
+		return 0, ""
+	}
+	return userId, name
+}
+
+var pseudonymAdjectives = []string{
+	"Amber", "Bitter", "Bold", "Bright", "Crisp", "Dark", "Dry", "Dusky",
+	"Fizzy", "Foamy", "Fruity", "Golden", "Hazy", "Hoppy", "Juicy", "Malty",
+	"Mellow", "Misty", "Nutty", "Oaky", "Pale", "Peaty", "Rich", "Roasty",
+	"Ruby", "Smoky", "Smooth", "Sour", "Spicy", "Stout", "Tart", "Zesty",
+}
+
+var pseudonymAnimals = []string{
+	"Badger", "Bear", "Beaver", "Bison", "Crane", "Deer", "Eagle", "Elk",
+	"Falcon", "Ferret", "Fox", "Gull", "Hare", "Hedgehog", "Heron", "Lynx",
+	"Marten", "Moose", "Otter", "Owl", "Puffin", "Raven", "Reindeer", "Robin",
+	"Salmon", "Seal", "Squirrel", "Stoat", "Swan", "Walrus", "Wolf", "Wren",
+}
+
+// pseudonym draws a name from the user's salted hash. Two users can draw
+// the same name; Pseudonyms tells them apart.
+func pseudonym(sum [sha256.Size]byte) string {
+	adjective := pseudonymAdjectives[binary.BigEndian.Uint32(sum[0:4])%uint32(len(pseudonymAdjectives))]
+	animal := pseudonymAnimals[binary.BigEndian.Uint32(sum[4:8])%uint32(len(pseudonymAnimals))]
+	return adjective + " " + animal
+}
+
+// pseudonymSum hashes the user id with the room's salt. The salt is secret
+// to the room, so pseudonyms cannot be mapped back to users by trying every
+// user id, and the same user gets different names in different rooms.
+func pseudonymSum(salt string, userId int) [sha256.Size]byte {
+	return sha256.Sum256([]byte(salt + ":" + strconv.Itoa(userId)))
+}
+
+// Pseudonyms are the names everyone in an anonymous room goes by, unique
+// within the room.
+type Pseudonyms struct {
+	salt  string
+	names map[int]string
+}
+
+// Name returns the user's pseudonym. Users who were not named with the room
+// get the name they drew, which may be shared.
+func (p Pseudonyms) Name(userId int) string {
+	if name, ok := p.names[userId]; ok {
+		return name
+	}
+	return pseudonym(pseudonymSum(p.salt, userId))
+}
+
+// dbtx is satisfied by *sql.DB and *sql.Tx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// LoadPseudonyms names everyone taking part in the room: its members, and
+// anyone who has voted in it since leaving. Names are stored the first time
+// a participant is seen and never change afterwards. Newcomers who draw a
+// name that is already taken are numbered, in the order of their salted
+// hash, which says nothing about who they are: "Hazy Fox 2".
+func LoadPseudonyms(ctx context.Context, db dbtx, roomId int, salt string) (Pseudonyms, error) {
+	p := Pseudonyms{salt: salt, names: map[int]string{}}
+	rows, err := db.QueryContext(ctx, `
+      SELECT user_id, pseudonym
+      FROM room_pseudonyms
+      WHERE room_id = ?
+    `,
+		roomId,
+	)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return p, err
+		}
+		p.names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return p, err
+	}
+
+	participants, err := getParticipants(ctx, db, roomId)
+	if err != nil {
+		return p, err
+	}
+	type newcomer struct {
+		userId int
+		sum    [sha256.Size]byte
+	}
+	newcomers := []newcomer{}
+	for _, id := range participants {
+		if _, ok := p.names[id]; !ok {
+			newcomers = append(newcomers, newcomer{userId: id, sum: pseudonymSum(salt, id)})
+		}
+	}
+	sort.Slice(newcomers, func(i, j int) bool {
+		return bytes.Compare(newcomers[i].sum[:], newcomers[j].sum[:]) < 0
+	})
+	for _, n := range newcomers {
+		name, err := assignPseudonym(ctx, db, roomId, n.userId, pseudonym(n.sum))
+		if err != nil {
+			return p, err
+		}
+		p.names[n.userId] = name
+	}
+	return p, nil
+}
+
+func getParticipants(ctx context.Context, db dbtx, roomId int) ([]int, error) {
+	rows, err := db.QueryContext(ctx, `
+      SELECT user_id
+      FROM user_room
+      WHERE room_id = ?
+      UNION
+      SELECT votes.user_id
+      FROM votes
+      JOIN beers ON beers.id = votes.beer_id
+      WHERE beers.room_id = ?
+    `,
+		roomId,
+		roomId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIds := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIds = append(userIds, id)
+	}
+	return userIds, rows.Err()
+}
+
+// assignPseudonym stores the first free variant of the drawn name. The
+// unique keys settle races: a name taken in the meantime moves on to the
+// next number, and a user named in the meantime keeps that name.
+func assignPseudonym(ctx context.Context, db dbtx, roomId int, userId int, drawn string) (string, error) {
+	for n := 1; ; n++ {
+		name := drawn
+		if n > 1 {
+			name += " " + strconv.Itoa(n)
+		}
+		res, err := db.ExecContext(ctx, `
+        INSERT IGNORE INTO room_pseudonyms (room_id, user_id, pseudonym)
+        VALUES (?, ?, ?)
+      `,
+			roomId,
+			userId,
+			name,
+		)
+		if err != nil {
+			return "", err
+		}
+		if inserted, err := res.RowsAffected(); err != nil {
+			return "", err
+		} else if inserted == 1 {
+			return name, nil
+		}
+
+		var existing string
+		err = db.QueryRowContext(ctx, `
+        SELECT pseudonym
+        FROM room_pseudonyms
+        WHERE room_id = ? AND user_id = ?
+      `,
+			roomId,
+			userId,
+		).Scan(&existing)
+		if err == nil {
+			return existing, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 )
 
 type BeerRepo struct {
@@ -12,21 +13,49 @@ type BeerRepo struct {
 }
 
 type Beer struct {
-	Id         int     `json:"id"`
-	Name       string  `json:"name"`
-	Style      *string `json:"style"`
-	PictureUrl *string `json:"pictureUrl"`
-	RoomId     int     `json:"roomId"`
-	Published  bool    `json:"published"`
+	Id         int      `json:"id"`
+	Name       string   `json:"name"`
+	Style      *string  `json:"style"`
+	Brewery    *string  `json:"brewery"`
+	Abv        *float64 `json:"abv"`
+	PictureUrl *string  `json:"pictureUrl"`
+	RoomId     int      `json:"roomId"`
+	Published  bool     `json:"published"`
+
+	Revealed     bool `json:"revealed"`
+	SampleNumber int  `json:"sampleNumber"`
+	blind        bool
+
+	VotingClosed   bool       `json:"votingClosed"`
+	VotingDeadline *time.Time `json:"votingDeadline"`
 }
 
 type Vote struct {
 	Id       int     `json:"id"`
 	UserId   int     `json:"userId"`
 	UserName string  `json:"name"`
-	Value    int     `json:"rating"`
+	Value    float64 `json:"rating"`
 	BeerId   int     `json:"beerId"`
 	Note     *string `json:"note"`
+	Scores   []Score `json:"scores,omitempty"`
+	Voted    bool    `json:"voted"`
+	// Version counts the saves of the vote. Sent back with a vote, it
+	// only lets the save through if the vote has not changed since.
+	Version int `json:"version,omitempty"`
+
+	Descriptors []VoteDescriptor `json:"descriptors,omitempty"`
+
+	// decidedAt is when the voter settled on this value. Of two edits to
+	// the same vote, the one decided last wins.
+	decidedAt time.Time
+	// syncId is the client id of a vote synced from an offline client.
+	syncId string
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx, so statements can be
+// shared between plain queries and transactions.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 }
 
 func NewBeerRepo(db *sql.DB) *BeerRepo {
@@ -68,6 +97,15 @@ func (br *BeerRepo) getVotesByBeerId(ctx context.Context, beerId int, roomId int
 		}
 		voteMap[v.UserId] = v
 	}
+	scores, err := br.getScoresForBeer(ctx, beerId)
+	if err != nil {
+		return nil, err
+	}
+	descriptors, err := br.getDescriptorsForBeer(ctx, beerId)
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err = br.db.QueryContext(ctx,
 		`
       SELECT
@@ -94,6 +132,9 @@ func (br *BeerRepo) getVotesByBeerId(ctx context.Context, beerId int, roomId int
 			return nil, err
 		}
 		if vote, ok := voteMap[u.Id]; ok {
+			vote.Voted = true
+			vote.Scores = scores[vote.Id]
+			vote.Descriptors = descriptors[vote.Id]
 			votes = append(votes, vote)
 		} else {
 			votes = append(votes, Vote{UserId: u.Id, UserName: u.Name})
@@ -102,78 +143,474 @@ func (br *BeerRepo) getVotesByBeerId(ctx context.Context, beerId int, roomId int
 	return votes, nil
 }
 
-func (br *BeerRepo) getBeerById(ctx context.Context, beerId int) (*Beer, error) {
+func (br *BeerRepo) getBeerById(ctx context.Context, beerId int, roomId int) (*Beer, error) {
 	row := br.db.QueryRowContext(ctx,
 		`
-      SELECT id, name, style, published, pictureurl
-      FROM beers
-      WHERE id = ?
+      SELECT
+        beers_votes.id,
+        beers_votes.name,
+        beers_votes.style,
+        beers_votes.brewery,
+        beers_votes.abv,
+        beers_votes.published,
+        beers_votes.pictureurl,
+        beers_votes.room_id,
+        beers_votes.revealed,
+        beers_votes.sample_number,
+        rooms.blind_mode,
+        beers_votes.voting_closed,
+        beers_votes.voting_deadline
+      FROM beers_votes
+      JOIN rooms ON rooms.id = beers_votes.room_id
+      WHERE beers_votes.id = ?
+      AND beers_votes.room_id = ?
     `,
 		beerId,
+		roomId,
 	)
 	var beer Beer
 	err := row.Scan(
 		&beer.Id,
 		&beer.Name,
 		&beer.Style,
+		&beer.Brewery,
+		&beer.Abv,
 		&beer.Published,
 		&beer.PictureUrl,
+		&beer.RoomId,
+		&beer.Revealed,
+		&beer.SampleNumber,
+		&beer.blind,
+		&beer.VotingClosed,
+		&beer.VotingDeadline,
 	)
 	return &beer, err
 }
 
-func (br *BeerRepo) addVoteOnBeerId(ctx context.Context, vote Vote) error {
+func (br *BeerRepo) revealBeer(ctx context.Context, beerId int, roomId int) error {
+	_, err := br.db.ExecContext(ctx,
+		`
+      UPDATE beers SET revealed = 1
+      WHERE beers.id = ? AND room_id = ?
+    `,
+		beerId,
+		roomId,
+	)
+	return err
+}
+
+func (br *BeerRepo) revealAllBeers(ctx context.Context, roomId int) error {
+	_, err := br.db.ExecContext(ctx,
+		`
+      UPDATE beers SET revealed = 1
+      WHERE room_id = ?
+    `,
+		roomId,
+	)
+	return err
+}
+
+// upsertVote inserts the user's vote on the beer, or locks the one already
+// there without changing it. The unique (beer, user) key makes this atomic,
+// so concurrent first votes cannot create duplicates.
+func (br *BeerRepo) upsertVote(ctx context.Context, ex execer, vote Vote) (int, bool, error) {
 	note := ""
 	if vote.Note != nil {
 		note = *vote.Note
 	}
-	fmt.Printf("%v %s\n", vote, note)
-	_, err := br.db.ExecContext(ctx, `
-      INSERT INTO votes (beer_id, user_id, points, note)
-      VALUES (?, ?, ?, ?)
+	res, err := ex.ExecContext(ctx, `
+      INSERT INTO votes (beer_id, user_id, points, note, decided_at)
+      VALUES (?, ?, ?, ?, ?)
+      ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)
     `,
 		vote.BeerId,
 		vote.UserId,
 		vote.Value,
 		note,
+		vote.decidedAt,
 	)
-	return err
+	if err != nil {
+		return 0, false, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, false, err
+	}
+	// One affected row is an insert; an existing vote left as it was
+	// counts as none.
+	n, err := res.RowsAffected()
+	return int(id), n == 1, err
 }
 
-func (br *BeerRepo) updateVoteOnBeerId(ctx context.Context, vote Vote) error {
+func (br *BeerRepo) updateVoteOnBeerId(ctx context.Context, ex execer, vote Vote) error {
 	note := ""
 	if vote.Note != nil {
 		note = *vote.Note
 	}
-	_, err := br.db.ExecContext(ctx, `
-      UPDATE votes SET points = ?, note = ?
-      WHERE id = ?
-
+	_, err := ex.ExecContext(ctx, `
+      UPDATE votes SET points = ?, note = ?, decided_at = ?, version = version + 1
+      WHERE id = ? AND user_id = ?
     `,
 		vote.Value,
 		note,
+		vote.decidedAt,
 		vote.Id,
+		vote.UserId,
 	)
 	return err
 }
 
+// saveVote writes the user's vote on the beer and its per-criterion scores in
+// one transaction, and returns the vote as saved. The vote is found by beer
+// and user, never by a client-supplied id, so users only ever write their
+// own. A vote decided before the stored one is refused with a
+// StaleVoteError, and a non-zero Version must match the stored vote's or the
+// save fails with a VersionConflictError. A synced vote is recorded under
+// its client id in the same transaction.
+func (br *BeerRepo) saveVote(ctx context.Context, vote Vote) (*Vote, error) {
+	tx, err := br.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if vote.decidedAt.IsZero() {
+		vote.decidedAt = time.Now().UTC()
+	}
+	if vote.syncId != "" {
+		if err := reserveVoteSync(ctx, tx, vote); err != nil {
+			return nil, err
+		}
+	}
+
+	id, inserted, err := br.upsertVote(ctx, tx, vote)
+	if err != nil {
+		return nil, err
+	}
+	expected := vote.Version
+	vote.Id = id
+
+	var old *Vote
+	if inserted {
+		if expected != 0 {
+			return nil, VersionConflictError{ErrorInfo: "The vote has changed since it was loaded"}
+		}
+		vote.Version = 1
+	} else {
+		old, err = getVoteForUpdate(ctx, tx, vote.Id)
+		if err != nil {
+			return nil, err
+		}
+		if expected != 0 && expected != old.Version {
+			return nil, VersionConflictError{
+				Current:   old.Version,
+				ErrorInfo: "The vote has changed since it was loaded",
+			}
+		}
+		if vote.decidedAt.Before(old.decidedAt) {
+			return nil, StaleVoteError{ErrorInfo: "A newer version of this vote has already been saved"}
+		}
+		if err := br.updateVoteOnBeerId(ctx, tx, vote); err != nil {
+			return nil, err
+		}
+		vote.Version = old.Version + 1
+	}
+	if err := recordVoteChange(ctx, tx, old, vote); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+      DELETE FROM vote_scores
+      WHERE vote_id = ?
+    `,
+		vote.Id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, score := range vote.Scores {
+		_, err = tx.ExecContext(ctx, `
+        INSERT INTO vote_scores (vote_id, criterion_id, score)
+        VALUES (?, ?, ?)
+      `,
+			vote.Id,
+			score.CriterionId,
+			score.Value,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+      DELETE FROM vote_descriptors
+      WHERE vote_id = ?
+    `,
+		vote.Id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range vote.Descriptors {
+		_, err = tx.ExecContext(ctx, `
+        INSERT INTO vote_descriptors (vote_id, descriptor, intensity)
+        VALUES (?, ?, ?)
+      `,
+			vote.Id,
+			d.Descriptor,
+			d.Intensity,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &vote, nil
+}
+
+func (br *BeerRepo) getScoresForBeer(ctx context.Context, beerId int) (map[int][]Score, error) {
+	rows, err := br.db.QueryContext(ctx,
+		`
+      SELECT vote_scores.vote_id, vote_scores.criterion_id, room_criteria.name, vote_scores.score
+      FROM vote_scores
+      JOIN votes ON votes.id = vote_scores.vote_id
+      JOIN room_criteria ON room_criteria.id = vote_scores.criterion_id
+      WHERE votes.beer_id = ?
+      ORDER BY room_criteria.position ASC
+    `,
+		beerId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	scores := map[int][]Score{}
+	for rows.Next() {
+		var voteId int
+		var sc Score
+		if err := rows.Scan(&voteId, &sc.CriterionId, &sc.Name, &sc.Value); err != nil {
+			return nil, err
+		}
+		scores[voteId] = append(scores[voteId], sc)
+	}
+	return scores, nil
+}
+
+func (br *BeerRepo) getDescriptorsForBeer(ctx context.Context, beerId int) (map[int][]VoteDescriptor, error) {
+	rows, err := br.db.QueryContext(ctx,
+		`
+      SELECT vote_descriptors.vote_id, vote_descriptors.descriptor, vote_descriptors.intensity
+      FROM vote_descriptors
+      JOIN votes ON votes.id = vote_descriptors.vote_id
+      WHERE votes.beer_id = ?
+      ORDER BY vote_descriptors.descriptor ASC
+    `,
+		beerId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	descriptors := map[int][]VoteDescriptor{}
+	for rows.Next() {
+		var voteId int
+		var d VoteDescriptor
+		if err := rows.Scan(&voteId, &d.Descriptor, &d.Intensity); err != nil {
+			return nil, err
+		}
+		descriptors[voteId] = append(descriptors[voteId], d)
+	}
+	return descriptors, nil
+}
+
+// getDescriptorCountsForBeer aggregates how often each descriptor was tagged
+// on the beer and returns the counts together with the number of votes.
+func (br *BeerRepo) getDescriptorCountsForBeer(ctx context.Context, beerId int) ([]DescriptorFrequency, int, error) {
+	row := br.db.QueryRowContext(ctx,
+		`
+      SELECT count(*)
+      FROM votes
+      WHERE beer_id = ?
+    `,
+		beerId,
+	)
+	var votes int
+	if err := row.Scan(&votes); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := br.db.QueryContext(ctx,
+		`
+      SELECT
+        vote_descriptors.descriptor,
+        count(*) as count,
+        AVG(vote_descriptors.intensity) as averageIntensity
+      FROM vote_descriptors
+      JOIN votes ON votes.id = vote_descriptors.vote_id
+      WHERE votes.beer_id = ?
+      GROUP BY vote_descriptors.descriptor
+      ORDER BY count DESC, vote_descriptors.descriptor ASC
+    `,
+		beerId,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	counts := []DescriptorFrequency{}
+	for rows.Next() {
+		var df DescriptorFrequency
+		if err := rows.Scan(&df.Id, &df.Count, &df.AverageIntensity); err != nil {
+			return nil, 0, err
+		}
+		counts = append(counts, df)
+	}
+	return counts, votes, nil
+}
+
+func (br *BeerRepo) getRubricForRoom(ctx context.Context, roomId int) (Rubric, error) {
+	rows, err := br.db.QueryContext(ctx,
+		`
+      SELECT id, name, scale_min, scale_max, scale_step, allow_half_points, weight
+      FROM room_criteria
+      WHERE room_id = ?
+      ORDER BY position ASC
+    `,
+		roomId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rubric := Rubric{}
+	for rows.Next() {
+		var c Criterion
+		err := rows.Scan(
+			&c.Id,
+			&c.Name,
+			&c.Scale.Min,
+			&c.Scale.Max,
+			&c.Scale.Step,
+			&c.Scale.AllowHalfPoints,
+			&c.Weight,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rubric = append(rubric, c)
+	}
+	return rubric, nil
+}
+
+func (br *BeerRepo) replaceRubric(ctx context.Context, roomId int, rubric Rubric) error {
+	tx, err := br.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+      DELETE FROM room_criteria
+      WHERE room_id = ?
+    `,
+		roomId,
+	)
+	if err != nil {
+		return err
+	}
+	for i, c := range rubric {
+		_, err = tx.ExecContext(ctx, `
+        INSERT INTO room_criteria
+          (room_id, name, position, scale_min, scale_max, scale_step, allow_half_points, weight)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+      `,
+			roomId,
+			c.Name,
+			i,
+			c.Scale.Min,
+			c.Scale.Max,
+			c.Scale.Step,
+			c.Scale.AllowHalfPoints,
+			c.Weight,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (br *BeerRepo) countVotesInRoom(ctx context.Context, roomId int) (int, error) {
+	row := br.db.QueryRowContext(ctx,
+		`
+      SELECT count(*)
+      FROM votes
+      JOIN beers ON beers.id = votes.beer_id
+      WHERE beers.room_id = ?
+    `,
+		roomId,
+	)
+	var count int
+	err := row.Scan(&count)
+	return count, err
+}
+
+func (br *BeerRepo) getRatingScaleForRoom(ctx context.Context, roomId int) (RatingScale, error) {
+	row := br.db.QueryRowContext(ctx,
+		`
+      SELECT scale_min, scale_max, scale_step, allow_half_points
+      FROM rooms
+      WHERE id = ?
+    `,
+		roomId,
+	)
+	var scale RatingScale
+	err := row.Scan(
+		&scale.Min,
+		&scale.Max,
+		&scale.Step,
+		&scale.AllowHalfPoints,
+	)
+	return scale, err
+}
+
+func (br *BeerRepo) getRoomScaleForBeer(ctx context.Context, beerId int) (int, RatingScale, error) {
+	row := br.db.QueryRowContext(ctx,
+		`
+      SELECT rooms.id, rooms.scale_min, rooms.scale_max, rooms.scale_step, rooms.allow_half_points
+      FROM beers
+      JOIN rooms ON rooms.id = beers.room_id
+      WHERE beers.id = ?
+    `,
+		beerId,
+	)
+	var roomId int
+	var scale RatingScale
+	err := row.Scan(
+		&roomId,
+		&scale.Min,
+		&scale.Max,
+		&scale.Step,
+		&scale.AllowHalfPoints,
+	)
+	return roomId, scale, err
+}
+
 func (br *BeerRepo) addNewBeer(ctx context.Context, beer Beer) error {
 	_, err := br.db.ExecContext(ctx, `
-    INSERT INTO beers (name, style, pictureurl, room_id)
-    VALUES(?, ?, ?, ?)
+    INSERT INTO beers (name, style, brewery, abv, pictureurl, room_id)
+    VALUES(?, ?, ?, ?, ?, ?)
     `,
 		beer.Name,
 		beer.Style,
+		beer.Brewery,
+		beer.Abv,
 		beer.PictureUrl,
 		beer.RoomId,
 	)
 	return err
 }
 
-func (br *BeerRepo) getBeersByUserVotes(ctx context.Context, userId int) ([]Beer, error) {
-	return []Beer{}, nil
-}
-
 func (br *BeerRepo) getRandomBeerInRoom(ctx context.Context, roomId int) (*Beer, error) {
 	row := br.db.QueryRowContext(ctx,
 		`
@@ -294,7 +731,7 @@ func (br *BeerRepo) unpublishRatingsForBeer(ctx context.Context, beerId int, roo
 func (br *BeerRepo) getMyRatingOnBeer(ctx context.Context, beerId int, userId int) (*Vote, error) {
 	row := br.db.QueryRowContext(ctx,
 		`
-      SELECT id, points, note
+      SELECT id, points, note, version
       FROM votes
       WHERE beer_id = ?
       AND user_id = ?
@@ -308,6 +745,7 @@ func (br *BeerRepo) getMyRatingOnBeer(ctx context.Context, beerId int, userId in
 		&vote.Id,
 		&vote.Value,
 		&note,
+		&vote.Version,
 	)
 	if note != nil {
 		vote.Note = note
@@ -315,17 +753,32 @@ func (br *BeerRepo) getMyRatingOnBeer(ctx context.Context, beerId int, userId in
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
-	return &vote, err
+	if err != nil {
+		return nil, err
+	}
+	scores, err := br.getScoresForBeer(ctx, beerId)
+	if err != nil {
+		return nil, err
+	}
+	vote.Scores = scores[vote.Id]
+	descriptors, err := br.getDescriptorsForBeer(ctx, beerId)
+	if err != nil {
+		return nil, err
+	}
+	vote.Descriptors = descriptors[vote.Id]
+	return &vote, nil
 }
 
 func (br *BeerRepo) updateBeer(ctx context.Context, beer Beer, roomId int) error {
 	_, err := br.db.ExecContext(ctx, `
       UPDATE beers
-      SET name = ?, style = ?, pictureurl = ?
+      SET name = ?, style = ?, brewery = ?, abv = ?, pictureurl = ?
       WHERE id = ? AND room_id = ?
     `,
 		beer.Name,
 		beer.Style,
+		beer.Brewery,
+		beer.Abv,
 		beer.PictureUrl,
 		beer.Id,
 		roomId,
@@ -0,0 +1,18 @@
+package beers
+
+import "fmt"
+
+func SampleName(sampleNumber int) string {
+	return fmt.Sprintf("Sample #%d", sampleNumber)
+}
+
+// Conceal replaces everything that identifies the beer with its sample
+// number. It is used for participants in blind rooms until the beer is
+// revealed.
+func (b *Beer) Conceal() {
+	b.Name = SampleName(b.SampleNumber)
+	b.Style = nil
+	b.Brewery = nil
+	b.Abv = nil
+	b.PictureUrl = nil
+}
@@ -0,0 +1,56 @@
+package beers
+
+import (
+	"fmt"
+
+	"skafteresort.se/beers/internal/flavors"
+)
+
+const (
+	minIntensity      = 1
+	maxIntensity      = 5
+	maxVoteDescriptor = 20
+)
+
+type VoteDescriptor struct {
+	Descriptor string `json:"descriptor"`
+	Intensity  *int   `json:"intensity,omitempty"`
+}
+
+type DescriptorFrequency struct {
+	flavors.Descriptor
+	Count            int      `json:"count"`
+	Frequency        float64  `json:"frequency"`
+	AverageIntensity *float64 `json:"averageIntensity"`
+}
+
+type FlavorProfile struct {
+	BeerId      int                   `json:"beerId"`
+	Votes       int                   `json:"votes"`
+	Descriptors []DescriptorFrequency `json:"descriptors"`
+	Categories  map[string]int        `json:"categories"`
+}
+
+func validateDescriptors(taxonomy *flavors.Taxonomy, descriptors []VoteDescriptor) error {
+	if len(descriptors) > maxVoteDescriptor {
+		return InvalidRatingError{
+			ErrorInfo: fmt.Sprintf("A vote can have at most %d descriptors", maxVoteDescriptor),
+		}
+	}
+	seen := map[string]bool{}
+	for _, d := range descriptors {
+		if _, ok := taxonomy.Lookup(d.Descriptor); !ok {
+			return InvalidRatingError{ErrorInfo: fmt.Sprintf("Unknown descriptor %q", d.Descriptor)}
+		}
+		if seen[d.Descriptor] {
+			return InvalidRatingError{ErrorInfo: fmt.Sprintf("Duplicate descriptor %q", d.Descriptor)}
+		}
+		seen[d.Descriptor] = true
+		if d.Intensity != nil && (*d.Intensity < minIntensity || *d.Intensity > maxIntensity) {
+			return InvalidRatingError{
+				ErrorInfo: fmt.Sprintf("Intensity must be between %d and %d", minIntensity, maxIntensity),
+			}
+		}
+	}
+	return nil
+}
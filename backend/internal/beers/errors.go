@@ -0,0 +1,68 @@
+package beers
+
+type InvalidRatingError struct {
+	ErrorInfo string
+}
+
+func (e InvalidRatingError) Error() string {
+	return e.ErrorInfo
+}
+
+type ConflictError struct {
+	ErrorInfo string
+}
+
+func (e ConflictError) Error() string {
+	return e.ErrorInfo
+}
+
+type InvalidQueryError struct {
+	ErrorInfo string
+}
+
+func (e InvalidQueryError) Error() string {
+	return e.ErrorInfo
+}
+
+// VotingClosedError is returned for votes on beers that do not accept
+// votes. Code tells clients why.
+type VotingClosedError struct {
+	Code      string
+	ErrorInfo string
+}
+
+func (e VotingClosedError) Error() string {
+	return e.ErrorInfo
+}
+
+// StaleVoteError is returned for votes that were decided before the version
+// already saved.
+type StaleVoteError struct {
+	ErrorInfo string
+}
+
+func (e StaleVoteError) Error() string {
+	return e.ErrorInfo
+}
+
+// AlreadySyncedError is returned for synced votes whose client id has
+// already been used.
+type AlreadySyncedError struct {
+	ErrorInfo string
+}
+
+func (e AlreadySyncedError) Error() string {
+	return e.ErrorInfo
+}
+
+// VersionConflictError is returned when a vote was saved against a version
+// other than the stored one. Current is the stored version, 0 if the user
+// has not voted yet.
+type VersionConflictError struct {
+	Current   int
+	ErrorInfo string
+}
+
+func (e VersionConflictError) Error() string {
+	return e.ErrorInfo
+}
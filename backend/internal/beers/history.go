@@ -0,0 +1,130 @@
+package beers
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// VoteChange is one entry in a vote's audit trail. The first entry of every
+// vote has no old values, and VoteId is nil once the vote has been deleted.
+type VoteChange struct {
+	Id           int       `json:"id"`
+	VoteId       *int      `json:"voteId"`
+	UserId       int       `json:"userId"`
+	UserName     string    `json:"name"`
+	OldValue     *float64  `json:"oldRating"`
+	NewValue     float64   `json:"newRating"`
+	OldNote      *string   `json:"oldNote"`
+	NewNote      *string   `json:"newNote"`
+	AfterPublish bool      `json:"afterPublish"`
+	ChangedAt    time.Time `json:"changedAt"`
+}
+
+// recordVoteChange adds an entry to the vote's audit trail, marking whether
+// the beer's ratings were already published. Missing notes are stored as
+// NULL on both sides. Saves that change nothing are not recorded.
+func recordVoteChange(ctx context.Context, tx *sql.Tx, old *Vote, vote Vote) error {
+	var oldValue *float64
+	var oldNote *string
+	if old != nil {
+		oldNote = old.Note
+		oldValue = &old.Value
+		if old.Value == vote.Value && noteText(old.Note) == noteText(vote.Note) {
+			return nil
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `
+      INSERT INTO vote_history (vote_id, beer_id, user_id, old_points, new_points, old_note, new_note, after_publish)
+      SELECT ?, beers.id, ?, ?, ?, ?, ?, beers.published
+      FROM beers
+      WHERE beers.id = ?
+    `,
+		vote.Id,
+		vote.UserId,
+		oldValue,
+		vote.Value,
+		oldNote,
+		vote.Note,
+		vote.BeerId,
+	)
+	return err
+}
+
+// noteText treats a missing note as an empty one, so clearing a note that
+// was never set is not a change.
+func noteText(note *string) string {
+	if note == nil {
+		return ""
+	}
+	return *note
+}
+
+// getVoteForUpdate locks the stored vote until the transaction ends, so the
+// recorded old values are the ones actually replaced.
+func getVoteForUpdate(ctx context.Context, tx *sql.Tx, voteId int) (*Vote, error) {
+	var v Vote
+	err := tx.QueryRowContext(ctx, `
+      SELECT id, points, note, decided_at, version
+      FROM votes
+      WHERE id = ?
+      FOR UPDATE
+    `,
+		voteId,
+	).Scan(&v.Id, &v.Value, &v.Note, &v.decidedAt, &v.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (br *BeerRepo) getVoteHistoryForBeer(ctx context.Context, beerId int, roomId int) ([]VoteChange, error) {
+	rows, err := br.db.QueryContext(ctx, `
+      SELECT
+        vote_history.id,
+        vote_history.vote_id,
+        users.id,
+        IF(users.name != '', users.name, users.username),
+        vote_history.old_points,
+        vote_history.new_points,
+        vote_history.old_note,
+        vote_history.new_note,
+        vote_history.after_publish,
+        vote_history.changed_at
+      FROM vote_history
+      JOIN beers ON beers.id = vote_history.beer_id
+      JOIN users ON users.id = vote_history.user_id
+      WHERE beers.id = ? AND beers.room_id = ?
+      ORDER BY vote_history.changed_at ASC, vote_history.id ASC
+    `,
+		beerId,
+		roomId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []VoteChange{}
+	for rows.Next() {
+		var c VoteChange
+		err := rows.Scan(
+			&c.Id,
+			&c.VoteId,
+			&c.UserId,
+			&c.UserName,
+			&c.OldValue,
+			&c.NewValue,
+			&c.OldNote,
+			&c.NewNote,
+			&c.AfterPublish,
+			&c.ChangedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,251 @@
+package beers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	JournalSortDate  = "date"
+	JournalSortScore = "score"
+	JournalSortName  = "name"
+
+	DefaultJournalLimit = 50
+	MaxJournalLimit     = 100
+)
+
+type JournalEntry struct {
+	VoteId      int       `json:"voteId"`
+	Rating      float64   `json:"rating"`
+	Note        *string   `json:"note"`
+	RatedAt     time.Time `json:"ratedAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	RoomId      int       `json:"roomId"`
+	RoomName    string    `json:"roomName"`
+	PlannedDate string    `json:"plannedDate"`
+	RoomAverage *float64  `json:"roomAverage"`
+	Beer        Beer      `json:"beer"`
+}
+
+type Journal struct {
+	Entries    []JournalEntry `json:"entries"`
+	NextCursor *string        `json:"nextCursor"`
+}
+
+// JournalFilter narrows and orders a user's journal. Zero values mean no
+// filter; Sort defaults to the newest ratings first.
+type JournalFilter struct {
+	Style    string
+	RoomId   int
+	From     *time.Time
+	To       *time.Time
+	MinScore *float64
+	MaxScore *float64
+	Sort     string
+	Asc      bool
+	Cursor   string
+	Limit    int
+}
+
+// journalCursor is the sort key and vote id of the last entry on a page.
+type journalCursor struct {
+	Sort  string    `json:"sort"`
+	Date  time.Time `json:"date,omitempty"`
+	Score float64   `json:"score,omitempty"`
+	Name  string    `json:"name,omitempty"`
+	Id    int       `json:"id"`
+}
+
+// journalName is what the user saw the beer as, so sorting blind beers by
+// name does not give away their real names.
+const journalName = `IF(rooms.blind_mode AND NOT beers_votes.revealed,
+  CONCAT('Sample #', beers_votes.sample_number),
+  beers_votes.name)`
+
+var journalSortColumns = map[string]string{
+	JournalSortDate:  "votes.created_at",
+	JournalSortScore: "votes.points",
+	JournalSortName:  journalName,
+}
+
+func (f JournalFilter) Valid() error {
+	if _, ok := journalSortColumns[f.Sort]; !ok {
+		return InvalidQueryError{ErrorInfo: "Unknown sort " + f.Sort}
+	}
+	if f.Limit < 1 || f.Limit > MaxJournalLimit {
+		return InvalidQueryError{ErrorInfo: "Limit must be between 1 and 100"}
+	}
+	if f.From != nil && f.To != nil && f.From.After(*f.To) {
+		return InvalidQueryError{ErrorInfo: "From must be before to"}
+	}
+	if f.MinScore != nil && f.MaxScore != nil && *f.MinScore > *f.MaxScore {
+		return InvalidQueryError{ErrorInfo: "Min score must not exceed max score"}
+	}
+	return nil
+}
+
+func encodeJournalCursor(c journalCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJournalCursor(s string, sort string) (journalCursor, error) {
+	var c journalCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, InvalidQueryError{ErrorInfo: "Invalid cursor"}
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort {
+		return c, InvalidQueryError{ErrorInfo: "Invalid cursor"}
+	}
+	return c, nil
+}
+
+func (br *BeerRepo) getBeersByUserVotes(ctx context.Context, userId int, f JournalFilter) (*Journal, error) {
+	where := []string{"votes.user_id = ?"}
+	args := []any{userId}
+
+	if f.Style != "" {
+		// Unrevealed blind beers never match, or the filter would reveal
+		// their style.
+		where = append(where, "beers_votes.style = ?", "(rooms.blind_mode = FALSE OR beers_votes.revealed = TRUE)")
+		args = append(args, f.Style)
+	}
+	if f.RoomId != 0 {
+		where = append(where, "rooms.id = ?")
+		args = append(args, f.RoomId)
+	}
+	if f.From != nil {
+		where = append(where, "votes.created_at >= ?")
+		args = append(args, *f.From)
+	}
+	if f.To != nil {
+		where = append(where, "votes.created_at < ?")
+		args = append(args, *f.To)
+	}
+	if f.MinScore != nil {
+		where = append(where, "votes.points >= ?")
+		args = append(args, *f.MinScore)
+	}
+	if f.MaxScore != nil {
+		where = append(where, "votes.points <= ?")
+		args = append(args, *f.MaxScore)
+	}
+
+	column := journalSortColumns[f.Sort]
+	direction, compare := "DESC", "<"
+	if f.Asc {
+		direction, compare = "ASC", ">"
+	}
+	if f.Cursor != "" {
+		c, err := decodeJournalCursor(f.Cursor, f.Sort)
+		if err != nil {
+			return nil, err
+		}
+		var key any
+		switch f.Sort {
+		case JournalSortDate:
+			key = c.Date
+		case JournalSortScore:
+			key = c.Score
+		case JournalSortName:
+			key = c.Name
+		}
+		where = append(where, "("+column+" "+compare+" ? OR ("+column+" = ? AND votes.id "+compare+" ?))")
+		args = append(args, key, key, c.Id)
+	}
+	args = append(args, f.Limit+1)
+
+	rows, err := br.db.QueryContext(ctx, `
+      SELECT
+        votes.id,
+        votes.points,
+        votes.note,
+        votes.created_at,
+        votes.updated_at,
+        rooms.id,
+        rooms.name,
+        rooms.planned_date,
+        rooms.blind_mode,
+        beers_votes.id,
+        beers_votes.name,
+        beers_votes.style,
+        beers_votes.brewery,
+        beers_votes.abv,
+        beers_votes.pictureurl,
+        beers_votes.published,
+        beers_votes.revealed,
+        beers_votes.sample_number,
+        beers_votes.average
+      FROM votes
+      JOIN beers_votes ON beers_votes.id = votes.beer_id
+      JOIN rooms ON rooms.id = beers_votes.room_id
+      WHERE `+strings.Join(where, " AND ")+`
+      ORDER BY `+column+` `+direction+`, votes.id `+direction+`
+      LIMIT ?
+    `,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	journal := Journal{Entries: []JournalEntry{}}
+	for rows.Next() {
+		var e JournalEntry
+		var average *float64
+		err := rows.Scan(
+			&e.VoteId,
+			&e.Rating,
+			&e.Note,
+			&e.RatedAt,
+			&e.UpdatedAt,
+			&e.RoomId,
+			&e.RoomName,
+			&e.PlannedDate,
+			&e.Beer.blind,
+			&e.Beer.Id,
+			&e.Beer.Name,
+			&e.Beer.Style,
+			&e.Beer.Brewery,
+			&e.Beer.Abv,
+			&e.Beer.PictureUrl,
+			&e.Beer.Published,
+			&e.Beer.Revealed,
+			&e.Beer.SampleNumber,
+			&average,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.Beer.RoomId = e.RoomId
+		if e.Beer.Published {
+			e.RoomAverage = average
+		}
+		if e.Beer.blind && !e.Beer.Revealed {
+			e.Beer.Conceal()
+		}
+		journal.Entries = append(journal.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(journal.Entries) > f.Limit {
+		journal.Entries = journal.Entries[:f.Limit]
+		last := journal.Entries[f.Limit-1]
+		cursor := encodeJournalCursor(journalCursor{
+			Sort:  f.Sort,
+			Date:  last.RatedAt,
+			Score: last.Rating,
+			Name:  last.Beer.Name,
+			Id:    last.VoteId,
+		})
+		journal.NextCursor = &cursor
+	}
+	return &journal, nil
+}
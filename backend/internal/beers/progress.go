@@ -0,0 +1,200 @@
+package beers
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress shows who has voted on a beer and who the room is waiting on.
+// Spectators cannot vote and are not counted.
+type Progress struct {
+	BeerId  int     `json:"beerId"`
+	Voted   []Voter `json:"voted"`
+	Waiting []Voter `json:"waiting"`
+	Total   int     `json:"total"`
+	Percent float64 `json:"percent"`
+}
+
+func (p Progress) Complete() bool {
+	return p.Total > 0 && len(p.Waiting) == 0
+}
+
+type autoPublish struct {
+	enabled bool
+	after   time.Duration
+}
+
+func (br *BeerRepo) getEligibleVoters(ctx context.Context, beerId int, roomId int) ([]Voter, error) {
+	rows, err := br.db.QueryContext(ctx, `
+      SELECT
+        users.id,
+        IF(users.name != '', users.name, users.username) AS userName,
+        EXISTS (
+          SELECT 1
+          FROM votes
+          WHERE votes.beer_id = ? AND votes.user_id = users.id
+        )
+      FROM user_room
+      JOIN users ON users.id = user_room.user_id
+      WHERE user_room.room_id = ?
+      AND user_room.role != 'spectator'
+      ORDER BY userName, users.id
+    `,
+		beerId,
+		roomId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	voters := []Voter{}
+	for rows.Next() {
+		var v Voter
+		if err := rows.Scan(&v.UserId, &v.Name, &v.Voted); err != nil {
+			return nil, err
+		}
+		voters = append(voters, v)
+	}
+	return voters, rows.Err()
+}
+
+// getAutoPublish only enables auto-publishing in rooms that are running, so
+// deadlines passing in finished rooms do not change their results, and only
+// for beers that have not been auto-published before.
+func (br *BeerRepo) getAutoPublish(ctx context.Context, beerId int) (autoPublish, error) {
+	var auto autoPublish
+	var seconds int
+	err := br.db.QueryRowContext(ctx, `
+      SELECT
+        rooms.auto_publish AND rooms.state IN ('open', 'live') AND NOT beers.auto_published,
+        rooms.auto_publish_after
+      FROM beers
+      JOIN rooms ON rooms.id = beers.room_id
+      WHERE beers.id = ?
+    `,
+		beerId,
+	).Scan(&auto.enabled, &seconds)
+	auto.after = time.Duration(seconds) * time.Second
+	return auto, err
+}
+
+// claimAutoPublish marks the beer as auto-published and reports whether
+// this caller was the one to do so.
+func (br *BeerRepo) claimAutoPublish(ctx context.Context, beerId int) (bool, error) {
+	res, err := br.db.ExecContext(ctx, `
+      UPDATE beers SET auto_published = 1
+      WHERE id = ? AND auto_published = 0
+    `,
+		beerId,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// startAutoPublishTimeout schedules the beer to be auto-published unless it
+// already is, and reports whether it did. The host's own deadline on the
+// beer is left alone.
+func (br *BeerRepo) startAutoPublishTimeout(ctx context.Context, beerId int, at time.Time) (bool, error) {
+	res, err := br.db.ExecContext(ctx, `
+      UPDATE beers SET auto_publish_at = ?
+      WHERE id = ? AND auto_publish_at IS NULL
+    `,
+		at,
+		beerId,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *BeerService) GetProgress(ctx context.Context, beerId int, roomId int) (*Progress, error) {
+	voters, err := s.beerRepo.getEligibleVoters(ctx, beerId, roomId)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := Progress{
+		BeerId:  beerId,
+		Voted:   []Voter{},
+		Waiting: []Voter{},
+		Total:   len(voters),
+	}
+	for _, v := range voters {
+		if v.Voted {
+			progress.Voted = append(progress.Voted, v)
+		} else {
+			progress.Waiting = append(progress.Waiting, v)
+		}
+	}
+	if progress.Total > 0 {
+		progress.Percent = math.Round(float64(len(progress.Voted))/float64(progress.Total)*1000) / 10
+	}
+	return &progress, nil
+}
+
+// trackProgress tells the hosts how far voting on the beer has come. In
+// rooms that auto-publish, the ratings are published once everyone has
+// voted, and the first vote starts the room's timeout.
+func (s *BeerService) trackProgress(ctx context.Context, beerId int, roomId int) error {
+	progress, err := s.GetProgress(ctx, beerId, roomId)
+	if err != nil {
+		return err
+	}
+
+	waiting := make([]string, 0, len(progress.Waiting))
+	for _, v := range progress.Waiting {
+		waiting = append(waiting, strconv.Itoa(v.UserId))
+	}
+	cMessage := s.centrifugo.CreateHostsMessage(roomId, "voting-progress", map[string]string{
+		"beerId":  strconv.Itoa(beerId),
+		"voted":   strconv.Itoa(len(progress.Voted)),
+		"total":   strconv.Itoa(progress.Total),
+		"percent": strconv.FormatFloat(progress.Percent, 'f', -1, 64),
+		"waiting": strings.Join(waiting, ","),
+	})
+	s.centrifugo.HandleMessage(ctx, cMessage)
+
+	auto, err := s.beerRepo.getAutoPublish(ctx, beerId)
+	if err != nil || !auto.enabled {
+		return err
+	}
+	if progress.Complete() {
+		_, err := s.autoPublish(ctx, beerId, roomId)
+		return err
+	}
+	if auto.after > 0 {
+		at := time.Now().UTC().Add(auto.after)
+		started, err := s.beerRepo.startAutoPublishTimeout(ctx, beerId, at)
+		if err != nil {
+			return err
+		}
+		if started {
+			cMessage := s.centrifugo.CreateRoomMessage(roomId, "auto-publish-scheduled", map[string]string{
+				"beerId": strconv.Itoa(beerId),
+				"at":     at.Format(time.RFC3339),
+			})
+			s.centrifugo.HandleMessage(ctx, cMessage)
+		}
+	}
+	return nil
+}
+
+// autoPublish publishes the beer's ratings on the room's behalf and reports
+// whether it did. The beer is claimed first, so concurrent callers publish
+// it only once and a host who unpublishes it afterwards is not overruled.
+func (s *BeerService) autoPublish(ctx context.Context, beerId int, roomId int) (bool, error) {
+	claimed, err := s.beerRepo.claimAutoPublish(ctx, beerId)
+	if err != nil || !claimed {
+		return false, err
+	}
+	return true, s.PublishRatingsForBeer(ctx, beerId, roomId)
+}
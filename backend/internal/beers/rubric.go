@@ -0,0 +1,98 @@
+package beers
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultCriterionName = "overall"
+	maxCriteria          = 10
+)
+
+type Criterion struct {
+	Id     int         `json:"id"`
+	Name   string      `json:"name"`
+	Scale  RatingScale `json:"scale"`
+	Weight float64     `json:"weight"`
+}
+
+type Score struct {
+	CriterionId int     `json:"criterionId"`
+	Name        string  `json:"name,omitempty"`
+	Value       float64 `json:"score"`
+}
+
+// Rubric is the list of criteria a room scores on. A room without configured
+// criteria uses a single implicit "overall" criterion on the room's scale, in
+// which case votes carry only their value and no per-criterion scores.
+type Rubric []Criterion
+
+func DefaultRubric(scale RatingScale) Rubric {
+	return Rubric{{
+		Name:   DefaultCriterionName,
+		Scale:  scale,
+		Weight: 1,
+	}}
+}
+
+func (r Rubric) IsDefault() bool {
+	return len(r) == 1 && r[0].Id == 0
+}
+
+func (r Rubric) Valid() error {
+	if len(r) > maxCriteria {
+		return InvalidRatingError{
+			ErrorInfo: fmt.Sprintf("A rubric can have at most %d criteria", maxCriteria),
+		}
+	}
+	names := map[string]bool{}
+	for _, c := range r {
+		name := strings.ToLower(strings.TrimSpace(c.Name))
+		if name == "" {
+			return InvalidRatingError{ErrorInfo: "Criteria must have a name"}
+		}
+		if names[name] {
+			return InvalidRatingError{ErrorInfo: fmt.Sprintf("Duplicate criterion %q", c.Name)}
+		}
+		names[name] = true
+		if c.Weight <= 0 {
+			return InvalidRatingError{ErrorInfo: fmt.Sprintf("Weight of %q must be positive", c.Name)}
+		}
+		if err := c.Scale.Valid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Score validates one score per criterion and returns the weighted total on
+// the room scale. Each score is first normalized to its criterion's range so
+// that criteria with different scales can be combined.
+func (r Rubric) Score(scores []Score, roomScale RatingScale) (float64, error) {
+	byCriterion := map[int]float64{}
+	for _, sc := range scores {
+		if _, ok := byCriterion[sc.CriterionId]; ok {
+			return 0, InvalidRatingError{ErrorInfo: "Duplicate score for criterion"}
+		}
+		byCriterion[sc.CriterionId] = sc.Value
+	}
+	if len(byCriterion) != len(r) {
+		return 0, InvalidRatingError{ErrorInfo: "A score is required for every criterion"}
+	}
+
+	var weighted, weights float64
+	for _, c := range r {
+		v, ok := byCriterion[c.Id]
+		if !ok {
+			return 0, InvalidRatingError{ErrorInfo: fmt.Sprintf("Missing score for %q", c.Name)}
+		}
+		if err := c.Scale.Validate(v); err != nil {
+			return 0, InvalidRatingError{ErrorInfo: fmt.Sprintf("%s: %s", c.Name, err.Error())}
+		}
+		weighted += c.Weight * (v - c.Scale.Min) / (c.Scale.Max - c.Scale.Min)
+		weights += c.Weight
+	}
+
+	return roomScale.Min + (roomScale.Max-roomScale.Min)*weighted/weights, nil
+}
@@ -0,0 +1,64 @@
+package beers
+
+import (
+	"fmt"
+	"math"
+)
+
+const scaleEpsilon = 1e-9
+
+type RatingScale struct {
+	Min             float64 `json:"min"`
+	Max             float64 `json:"max"`
+	Step            float64 `json:"step"`
+	AllowHalfPoints bool    `json:"allowHalfPoints"`
+}
+
+var DefaultRatingScale = RatingScale{
+	Min:  0,
+	Max:  5,
+	Step: 1,
+}
+
+// increment is the smallest allowed difference between two ratings.
+func (s RatingScale) increment() float64 {
+	if s.AllowHalfPoints {
+		return s.Step / 2
+	}
+	return s.Step
+}
+
+func onGrid(v float64, inc float64) bool {
+	k := v / inc
+	return math.Abs(k-math.Round(k)) < scaleEpsilon*math.Max(1, math.Abs(k))
+}
+
+// Valid checks that the scale itself is usable: a non-empty range that is
+// evenly divided by the step.
+func (s RatingScale) Valid() error {
+	if s.Max <= s.Min {
+		return InvalidRatingError{ErrorInfo: "Scale max must be greater than min"}
+	}
+	if s.Step <= 0 {
+		return InvalidRatingError{ErrorInfo: "Scale step must be positive"}
+	}
+	if !onGrid(s.Max-s.Min, s.Step) {
+		return InvalidRatingError{ErrorInfo: "Scale range must be a multiple of the step"}
+	}
+	return nil
+}
+
+// Validate checks that a rating is within the scale and on one of its steps.
+func (s RatingScale) Validate(value float64) error {
+	if math.IsNaN(value) || value < s.Min-scaleEpsilon || value > s.Max+scaleEpsilon {
+		return InvalidRatingError{
+			ErrorInfo: fmt.Sprintf("Rating must be between %g and %g", s.Min, s.Max),
+		}
+	}
+	if !onGrid(value-s.Min, s.increment()) {
+		return InvalidRatingError{
+			ErrorInfo: fmt.Sprintf("Rating must be in steps of %g", s.increment()),
+		}
+	}
+	return nil
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"log/slog"
 	"strconv"
+	"time"
 
+	"skafteresort.se/beers/internal/flavors"
+	"skafteresort.se/beers/internal/numeric"
 	"skafteresort.se/beers/internal/providers"
 )
 
@@ -13,26 +16,32 @@ type BeerService struct {
 	beerRepo   *BeerRepo
 	logger     *slog.Logger
 	centrifugo *providers.CentrifugoProvider
+	taxonomy   *flavors.Taxonomy
 }
 
-func NewBeerService(br *BeerRepo, logger *slog.Logger, gp *providers.CentrifugoProvider) *BeerService {
+func NewBeerService(
+	br *BeerRepo,
+	logger *slog.Logger,
+	gp *providers.CentrifugoProvider,
+	taxonomy *flavors.Taxonomy,
+) *BeerService {
 	ts := BeerService{
 		beerRepo:   br,
 		logger:     logger,
 		centrifugo: gp,
+		taxonomy:   taxonomy,
 	}
 	return &ts
 }
 
-func (s *BeerService) GetVotesByBeerId(ctx context.Context, beerId int, roomId int) ([]Vote, error) {
-	return s.beerRepo.getVotesByBeerId(ctx, beerId, roomId)
-}
-
 func (s *BeerService) GetBeerById(ctx context.Context, beerId int, roomId int, isAdmin bool) (*Beer, error) {
-	beer, err := s.beerRepo.getBeerById(ctx, beerId)
+	beer, err := s.beerRepo.getBeerById(ctx, beerId, roomId)
 	if err != nil || beer == nil {
 		return nil, err
 	}
+	if beer.blind && !beer.Revealed && !isAdmin {
+		beer.Conceal()
+	}
 	if isAdmin {
 		cMessage := s.centrifugo.CreateMessageWithChannel(
 			fmt.Sprintf("rooms:%d-next-beer", roomId),
@@ -46,51 +55,75 @@ func (s *BeerService) GetBeerById(ctx context.Context, beerId int, roomId int, i
 	return beer, nil
 }
 
+// UpdateVoteOnBeerId saves the user's vote on the beer and returns it as
+// saved, with its new version.
 func (s *BeerService) UpdateVoteOnBeerId(
 	ctx context.Context,
 	vote Vote,
-) error {
-	if vote.Id != 0 {
-		err := s.beerRepo.updateVoteOnBeerId(ctx, vote)
-		if err != nil {
-			return err
+) (*Vote, error) {
+	vote.decidedAt = time.Now().UTC()
+	return s.castVote(ctx, vote)
+}
+
+// castVote checks, scores and saves the vote, then tells the room about it.
+func (s *BeerService) castVote(ctx context.Context, vote Vote) (*Vote, error) {
+	if err := s.checkVotingOpen(ctx, vote.BeerId); err != nil {
+		return nil, err
+	}
+
+	roomId, scale, err := s.beerRepo.getRoomScaleForBeer(ctx, vote.BeerId)
+	if err != nil {
+		return nil, err
+	}
+	rubric, err := s.getRubric(ctx, roomId, scale)
+	if err != nil {
+		return nil, err
+	}
+
+	if rubric.IsDefault() {
+		if err := scale.Validate(vote.Value); err != nil {
+			return nil, err
 		}
+		vote.Scores = nil
 	} else {
-		err := s.beerRepo.addVoteOnBeerId(ctx, vote)
+		total, err := rubric.Score(vote.Scores, scale)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		vote.Value = numeric.Round2(total)
 	}
 
-	note := ""
-	if vote.Note != nil {
-		note = *vote.Note
+	if err := validateDescriptors(s.taxonomy, vote.Descriptors); err != nil {
+		return nil, err
 	}
 
-	cMessage := s.centrifugo.CreateVoteMessage(
-		vote.BeerId,
-		vote.UserId,
-		vote.Value,
-		note,
-		vote.UserName,
-		"vote-updated",
-	)
-	s.centrifugo.HandleMessage(ctx, cMessage)
-	return nil
+	saved, err := s.beerRepo.saveVote(ctx, vote)
+	if err != nil {
+		return nil, err
+	}
 
+	// The vote is saved either way, so announcing it and tracking progress
+	// only log.
+	if err := s.announceVote(ctx, *saved); err != nil {
+		s.logger.Error("castVote/announce", "err", err)
+	}
+	if err := s.trackProgress(ctx, vote.BeerId, roomId); err != nil {
+		s.logger.Error("castVote/progress", "err", err)
+	}
+	return saved, nil
 }
 
 func (s *BeerService) AddNewBeer(
 	ctx context.Context,
-	name string,
-	beerType *string,
-	pictureUrl *string,
+	beer Beer,
 	roomId int,
 ) error {
 	b := Beer{
-		Name:       name,
-		Style:      beerType,
-		PictureUrl: pictureUrl,
+		Name:       beer.Name,
+		Style:      beer.Style,
+		Brewery:    beer.Brewery,
+		Abv:        beer.Abv,
+		PictureUrl: beer.PictureUrl,
 		RoomId:     roomId,
 	}
 	err := s.beerRepo.addNewBeer(ctx, b)
@@ -105,8 +138,19 @@ func (s *BeerService) AddNewBeer(
 	return nil
 }
 
-func (s *BeerService) GetBeersByUserVotes(ctx context.Context, userId int) ([]Beer, error) {
-	return s.beerRepo.getBeersByUserVotes(ctx, userId)
+// GetBeersByUserVotes returns the user's tasting journal: every beer they
+// have rated, across all rooms, one page at a time.
+func (s *BeerService) GetBeersByUserVotes(ctx context.Context, userId int, filter JournalFilter) (*Journal, error) {
+	if filter.Sort == "" {
+		filter.Sort = JournalSortDate
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultJournalLimit
+	}
+	if err := filter.Valid(); err != nil {
+		return nil, err
+	}
+	return s.beerRepo.getBeersByUserVotes(ctx, userId, filter)
 }
 
 func (s *BeerService) PublishRatingsForBeer(ctx context.Context, beerId int, roomId int) error {
@@ -115,7 +159,8 @@ func (s *BeerService) PublishRatingsForBeer(ctx context.Context, beerId int, roo
 	}
 	cMessage := s.centrifugo.CreateVoteMessage(beerId, 0, 0, "", "", "ratings-published")
 	s.centrifugo.HandleMessage(ctx, cMessage)
-	return nil
+	// Publishing the ratings ends the round for this beer.
+	return s.setVotingClosed(ctx, beerId, roomId, true, closedByPublish)
 }
 
 func (s *BeerService) UnpublishRatingsForBeer(ctx context.Context, beerId int, roomId int) error {
@@ -124,8 +169,11 @@ func (s *BeerService) UnpublishRatingsForBeer(ctx context.Context, beerId int, r
 	}
 	cMessage := s.centrifugo.CreateVoteMessage(beerId, 0, 0, "", "", "ratings-unpublished")
 	s.centrifugo.HandleMessage(ctx, cMessage)
-	return nil
+	// Only the close that publishing caused is undone: a beer a host closed
+	// stays closed, and unlike OpenVoting a passed deadline stays in force.
+	return s.reopenVoting(ctx, beerId, roomId, closedByPublish)
 }
+
 func (s *BeerService) GetMyRatingOnBeer(ctx context.Context, beerId int, userId int) (*Vote, error) {
 	return s.beerRepo.getMyRatingOnBeer(ctx, beerId, userId)
 }
@@ -164,6 +212,107 @@ func (s *BeerService) GetNextBeer(ctx context.Context, roomId int, oldBeerId int
 	return beer, nil
 }
 
+// GetBeer returns the beer as stored, without concealing it or announcing it
+// to the room.
+func (s *BeerService) GetBeer(ctx context.Context, beerId int, roomId int) (*Beer, error) {
+	return s.beerRepo.getBeerById(ctx, beerId, roomId)
+}
+
 func (s *BeerService) UpdateBeer(ctx context.Context, beer Beer, roomId int) error {
 	return s.beerRepo.updateBeer(ctx, beer, roomId)
 }
+
+func (s *BeerService) getRubric(ctx context.Context, roomId int, scale RatingScale) (Rubric, error) {
+	rubric, err := s.beerRepo.getRubricForRoom(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	if len(rubric) == 0 {
+		return DefaultRubric(scale), nil
+	}
+	return rubric, nil
+}
+
+func (s *BeerService) GetRubric(ctx context.Context, roomId int) (Rubric, error) {
+	scale, err := s.beerRepo.getRatingScaleForRoom(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	return s.getRubric(ctx, roomId, scale)
+}
+
+// SetRubric replaces the room's criteria, and an empty rubric restores the
+// default "overall" criterion. The rubric is locked once the first vote is
+// cast, since existing scores would no longer match the criteria.
+func (s *BeerService) SetRubric(ctx context.Context, roomId int, rubric Rubric) error {
+	if err := rubric.Valid(); err != nil {
+		return err
+	}
+	votes, err := s.beerRepo.countVotesInRoom(ctx, roomId)
+	if err != nil {
+		return err
+	}
+	if votes > 0 {
+		return ConflictError{ErrorInfo: "The rubric cannot be changed after voting has started"}
+	}
+	if err := s.beerRepo.replaceRubric(ctx, roomId, rubric); err != nil {
+		return err
+	}
+
+	cMessage := s.centrifugo.CreateBeerMessage(roomId, "rubric-updated")
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
+
+func (s *BeerService) GetTaxonomy() *flavors.Taxonomy {
+	return s.taxonomy
+}
+
+func (s *BeerService) GetFlavorProfile(ctx context.Context, beerId int) (*FlavorProfile, error) {
+	counts, votes, err := s.beerRepo.getDescriptorCountsForBeer(ctx, beerId)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := FlavorProfile{
+		BeerId:      beerId,
+		Votes:       votes,
+		Descriptors: []DescriptorFrequency{},
+		Categories:  map[string]int{},
+	}
+	for _, df := range counts {
+		// Descriptors removed from the taxonomy since they were tagged are
+		// still counted, just without a name and category.
+		if d, ok := s.taxonomy.Lookup(df.Id); ok {
+			df.Descriptor = d
+		}
+		if votes > 0 {
+			df.Frequency = float64(df.Count) / float64(votes)
+		}
+		if df.Category != "" {
+			profile.Categories[df.Category] += df.Count
+		}
+		profile.Descriptors = append(profile.Descriptors, df)
+	}
+	return &profile, nil
+}
+
+func (s *BeerService) RevealBeer(ctx context.Context, beerId int, roomId int) error {
+	if err := s.beerRepo.revealBeer(ctx, beerId, roomId); err != nil {
+		return err
+	}
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "beer-revealed", map[string]string{
+		"beerId": strconv.Itoa(beerId),
+	})
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
+
+func (s *BeerService) RevealAllBeers(ctx context.Context, roomId int) error {
+	if err := s.beerRepo.revealAllBeers(ctx, roomId); err != nil {
+		return err
+	}
+	cMessage := s.centrifugo.CreateBeerMessage(roomId, "beers-revealed")
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
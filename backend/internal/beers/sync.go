@@ -0,0 +1,239 @@
+package beers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MaxSyncBatch is the most votes a client may sync in one request.
+const MaxSyncBatch = 100
+
+const maxClientIdLength = 64
+
+// mysqlDuplicateKey is the server error for a row whose key is taken.
+const mysqlDuplicateKey = 1062
+
+type SyncStatus string
+
+const (
+	SyncApplied  SyncStatus = "applied"
+	SyncStale    SyncStatus = "stale"
+	SyncRejected SyncStatus = "rejected"
+	// SyncFailed votes could not be saved because of the server. They are
+	// not recorded, so the client can retry them.
+	SyncFailed SyncStatus = "failed"
+)
+
+const (
+	SyncCodeInvalidVote   = "invalid-vote"
+	SyncCodeInvalidRating = "invalid-rating"
+	SyncCodeBeerNotFound  = "beer-not-found"
+	SyncCodeServerError   = "server-error"
+)
+
+// SyncVote is a vote queued by a client while it was offline. ClientId
+// identifies the vote across retries and ClientTimestamp is when it was
+// cast on the device.
+type SyncVote struct {
+	ClientId string `json:"clientId"`
+	Vote
+	ClientTimestamp time.Time `json:"clientTimestamp"`
+}
+
+// SyncResult tells the client what became of a synced vote. Vote is the
+// user's vote on the beer as the server now has it, or nil if there is none.
+type SyncResult struct {
+	ClientId string     `json:"clientId"`
+	BeerId   int        `json:"beerId"`
+	Status   SyncStatus `json:"status"`
+	Code     string     `json:"code,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Vote     *Vote      `json:"vote"`
+}
+
+func (br *BeerRepo) getVoteSync(ctx context.Context, userId int, clientId string) (*SyncResult, error) {
+	res := SyncResult{ClientId: clientId}
+	err := br.db.QueryRowContext(ctx, `
+      SELECT beer_id, status, code, error
+      FROM vote_syncs
+      WHERE user_id = ? AND client_id = ?
+    `,
+		userId,
+		clientId,
+	).Scan(&res.BeerId, &res.Status, &res.Code, &res.Error)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// reserveVoteSync records a synced vote as applied in the transaction that
+// saves it, so the two are committed together. A client id that is already
+// recorded, or that a concurrent retry is applying, gives an
+// AlreadySyncedError once the retry is done.
+func reserveVoteSync(ctx context.Context, tx *sql.Tx, vote Vote) error {
+	_, err := tx.ExecContext(ctx, `
+      INSERT INTO vote_syncs (user_id, client_id, beer_id, status)
+      VALUES (?, ?, ?, ?)
+    `,
+		vote.UserId,
+		vote.syncId,
+		vote.BeerId,
+		SyncApplied,
+	)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateKey {
+		return AlreadySyncedError{ErrorInfo: "The vote has already been synced"}
+	}
+	return err
+}
+
+// recordVoteSync keeps the first outcome for a client id; retries never
+// replace it.
+func (br *BeerRepo) recordVoteSync(ctx context.Context, userId int, res SyncResult) error {
+	_, err := br.db.ExecContext(ctx, `
+      INSERT IGNORE INTO vote_syncs (user_id, client_id, beer_id, status, code, error)
+      VALUES (?, ?, ?, ?, ?, ?)
+    `,
+		userId,
+		res.ClientId,
+		res.BeerId,
+		res.Status,
+		res.Code,
+		res.Error,
+	)
+	return err
+}
+
+// SyncVotes saves a batch of votes queued offline by the user, in order.
+// Every client id is applied at most once: a retried vote gets its first
+// outcome back. Conflicting edits are settled by when they were cast, so a
+// vote cast before the one already saved comes back stale. Votes that cannot
+// be saved are rejected with a code, and votes the server fails to save come
+// back failed, without failing the rest of the batch.
+func (s *BeerService) SyncVotes(ctx context.Context, roomId int, userId int, votes []SyncVote) []SyncResult {
+	results := make([]SyncResult, 0, len(votes))
+	for _, sv := range votes {
+		res, err := s.syncVote(ctx, roomId, userId, sv)
+		if err != nil {
+			s.logger.Error("SyncVotes", "err", err, "clientId", sv.ClientId)
+			res = SyncResult{ClientId: sv.ClientId, BeerId: sv.BeerId}
+			res.fail()
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func (s *BeerService) syncVote(ctx context.Context, roomId int, userId int, sv SyncVote) (SyncResult, error) {
+	res := SyncResult{ClientId: sv.ClientId, BeerId: sv.BeerId}
+	if sv.ClientId == "" || len(sv.ClientId) > maxClientIdLength {
+		res.reject(SyncCodeInvalidVote, "Client id must be 1 to 64 characters")
+		return res, nil
+	}
+	if sv.ClientTimestamp.IsZero() {
+		res.reject(SyncCodeInvalidVote, "Client timestamp is required")
+		return res, nil
+	}
+
+	recorded, err := s.beerRepo.getVoteSync(ctx, userId, sv.ClientId)
+	if err != nil {
+		return res, err
+	}
+	if recorded != nil {
+		return s.recordedResult(ctx, userId, *recorded)
+	}
+
+	beerRoom, _, err := s.beerRepo.getRoomScaleForBeer(ctx, sv.BeerId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && beerRoom != roomId) {
+		res.reject(SyncCodeBeerNotFound, "Beer not found")
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+
+	// An applied vote is recorded along with it, see reserveVoteSync. The
+	// other outcomes save nothing, so they are recorded afterwards.
+	if err := s.applySyncVote(ctx, userId, sv); err != nil {
+		var closedErr VotingClosedError
+		switch {
+		case errors.As(err, &AlreadySyncedError{}):
+			recorded, err := s.beerRepo.getVoteSync(ctx, userId, sv.ClientId)
+			if err != nil {
+				return res, err
+			}
+			if recorded == nil {
+				return res, errors.New("synced vote was not recorded")
+			}
+			return s.recordedResult(ctx, userId, *recorded)
+		case errors.As(err, &StaleVoteError{}):
+			res.Status = SyncStale
+		case errors.As(err, &closedErr):
+			res.reject(closedErr.Code, closedErr.ErrorInfo)
+		case errors.As(err, &InvalidRatingError{}):
+			res.reject(SyncCodeInvalidRating, err.Error())
+		default:
+			return res, err
+		}
+		if err := s.beerRepo.recordVoteSync(ctx, userId, res); err != nil {
+			return res, err
+		}
+	} else {
+		res.Status = SyncApplied
+	}
+
+	res.Vote, err = s.beerRepo.getMyRatingOnBeer(ctx, sv.BeerId, userId)
+	if err != nil {
+		// The outcome is settled and recorded by now, so it is still
+		// reported; a retry brings the vote along.
+		s.logger.Error("syncVote/vote", "err", err, "clientId", sv.ClientId)
+	}
+	return res, nil
+}
+
+// recordedResult returns the outcome recorded for a retried vote, with the
+// user's vote as it is now.
+func (s *BeerService) recordedResult(ctx context.Context, userId int, recorded SyncResult) (SyncResult, error) {
+	var err error
+	recorded.Vote, err = s.beerRepo.getMyRatingOnBeer(ctx, recorded.BeerId, userId)
+	return recorded, err
+}
+
+// applySyncVote saves the vote as cast on the device. Synced votes settle
+// conflicts by time rather than version. A clock running ahead could
+// otherwise win every later conflict, so timestamps from the future count as
+// now.
+func (s *BeerService) applySyncVote(ctx context.Context, userId int, sv SyncVote) error {
+	now := time.Now().UTC()
+	vote := sv.Vote
+	vote.UserId = userId
+	vote.syncId = sv.ClientId
+	vote.Version = 0
+	vote.decidedAt = sv.ClientTimestamp.UTC()
+	if vote.decidedAt.After(now) {
+		vote.decidedAt = now
+	}
+	_, err := s.castVote(ctx, vote)
+	return err
+}
+
+func (r *SyncResult) reject(code string, info string) {
+	r.Status = SyncRejected
+	r.Code = code
+	r.Error = info
+}
+
+// fail reports a vote the server could not save, without recording it.
+func (r *SyncResult) fail() {
+	r.Status = SyncFailed
+	r.Code = SyncCodeServerError
+	r.Error = "The vote could not be saved, try again later"
+}
@@ -0,0 +1,238 @@
+package beers
+
+import (
+	"context"
+	"sort"
+
+	"skafteresort.se/beers/internal/providers"
+)
+
+// HostRatingsView decides what hosts see of other participants' ratings
+// before they are published.
+type HostRatingsView string
+
+const (
+	HostRatingsAll    HostRatingsView = "all"
+	HostRatingsCounts HostRatingsView = "counts"
+)
+
+func (v HostRatingsView) Valid() bool {
+	return v == HostRatingsAll || v == HostRatingsCounts
+}
+
+// RatingsVisible reports whether a viewer may see everyone's scores and
+// notes on a beer. Before publishing, participants only see their own vote
+// and who else has voted.
+func RatingsVisible(published bool, isAdmin bool, view HostRatingsView) bool {
+	return published || (isAdmin && view == HostRatingsAll)
+}
+
+// Voter tells hosts whether a participant has voted, even in anonymous
+// rooms.
+type Voter struct {
+	UserId int    `json:"userId"`
+	Name   string `json:"name"`
+	Voted  bool   `json:"voted"`
+}
+
+// Redact keeps who voted but drops what they voted.
+func (v *Vote) Redact() {
+	v.Id = 0
+	v.Value = 0
+	v.Note = nil
+	v.Scores = nil
+	v.Descriptors = nil
+}
+
+// ratingsPolicy is the room's say in who sees which ratings on a beer.
+type ratingsPolicy struct {
+	roomId    int
+	published bool
+	view      HostRatingsView
+	anonymity Anonymity
+	names     Pseudonyms
+}
+
+func (p ratingsPolicy) visible(isAdmin bool) bool {
+	return RatingsVisible(p.published, isAdmin, p.view)
+}
+
+func (br *BeerRepo) getRatingsPolicy(ctx context.Context, beerId int) (ratingsPolicy, error) {
+	var p ratingsPolicy
+	var salt string
+	err := br.db.QueryRowContext(ctx, `
+      SELECT
+        rooms.id,
+        beers.published,
+        rooms.host_ratings_view,
+        rooms.anonymity,
+        rooms.pseudonym_salt
+      FROM beers
+      JOIN rooms ON rooms.id = beers.room_id
+      WHERE beers.id = ?
+    `,
+		beerId,
+	).Scan(&p.roomId, &p.published, &p.view, &p.anonymity, &salt)
+	if err != nil || p.anonymity == AnonymityOff {
+		return p, err
+	}
+	p.names, err = LoadPseudonyms(ctx, br.db, p.roomId, salt)
+	return p, err
+}
+
+// CanSeeAllRatings reports whether the viewer may see every participant's
+// rating on the beer.
+func (s *BeerService) CanSeeAllRatings(ctx context.Context, beerId int, isAdmin bool) (bool, error) {
+	policy, err := s.beerRepo.getRatingsPolicy(ctx, beerId)
+	if err != nil {
+		return false, err
+	}
+	return policy.visible(isAdmin), nil
+}
+
+// GetVotesByBeerId lists every participant in the room with their vote on
+// the beer. Votes the viewer may not see yet are redacted to whether the
+// participant has voted. In anonymous rooms only cast votes are listed,
+// and everyone but the viewer is shown by pseudonym or not at all.
+func (s *BeerService) GetVotesByBeerId(ctx context.Context, beerId int, roomId int, viewerId int, isAdmin bool) ([]Vote, error) {
+	votes, err := s.beerRepo.getVotesByBeerId(ctx, beerId, roomId)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.beerRepo.getRatingsPolicy(ctx, beerId)
+	if err != nil {
+		return nil, err
+	}
+	visible := policy.visible(isAdmin)
+
+	if policy.anonymity == AnonymityOff {
+		if !visible {
+			for i := range votes {
+				if votes[i].UserId != viewerId {
+					votes[i].Redact()
+				}
+			}
+		}
+		return votes, nil
+	}
+
+	// The room's member order would give away who is who, so votes are
+	// ordered by pseudonym even when it is not shown.
+	sort.SliceStable(votes, func(i, j int) bool {
+		return policy.names.Name(votes[i].UserId) < policy.names.Name(votes[j].UserId)
+	})
+	anonymous := []Vote{}
+	for _, v := range votes {
+		if !v.Voted {
+			continue
+		}
+		if v.UserId != viewerId {
+			if visible {
+				v.Id = 0
+			} else {
+				v.Redact()
+			}
+			v.UserId, v.UserName = policy.anonymity.Identity(policy.names, v.UserId, v.UserName)
+		}
+		anonymous = append(anonymous, v)
+	}
+	return anonymous, nil
+}
+
+// GetVoters lists who has and has not voted on the beer, without their
+// votes.
+func (s *BeerService) GetVoters(ctx context.Context, beerId int, roomId int) ([]Voter, error) {
+	votes, err := s.beerRepo.getVotesByBeerId(ctx, beerId, roomId)
+	if err != nil {
+		return nil, err
+	}
+	voters := make([]Voter, 0, len(votes))
+	for _, v := range votes {
+		voters = append(voters, Voter{UserId: v.UserId, Name: v.UserName, Voted: v.Voted})
+	}
+	return voters, nil
+}
+
+// GetVoteHistory returns the beer's audit trail, hiding who made each
+// change in anonymous rooms.
+func (s *BeerService) GetVoteHistory(ctx context.Context, beerId int, roomId int) ([]VoteChange, error) {
+	history, err := s.beerRepo.getVoteHistoryForBeer(ctx, beerId, roomId)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.beerRepo.getRatingsPolicy(ctx, beerId)
+	if err != nil {
+		return nil, err
+	}
+	if policy.anonymity != AnonymityOff {
+		for i := range history {
+			c := &history[i]
+			c.VoteId = nil
+			c.UserId, c.UserName = policy.anonymity.Identity(policy.names, c.UserId, c.UserName)
+		}
+	}
+	return history, nil
+}
+
+// announceVote tells the beer channel about a vote. Until the ratings are
+// published the beer channel only hears that someone voted, and hosts who
+// may see the ratings get the full vote on their own channel. In anonymous
+// rooms the beer channel only hears how many votes there are, and hosts
+// only hear who voted.
+func (s *BeerService) announceVote(ctx context.Context, vote Vote) error {
+	policy, err := s.beerRepo.getRatingsPolicy(ctx, vote.BeerId)
+	if err != nil {
+		return err
+	}
+
+	note := ""
+	if vote.Note != nil {
+		note = *vote.Note
+	}
+	var cMessage providers.Message
+	switch {
+	case policy.anonymity != AnonymityOff:
+		// Hosts hear who voted on their own channel, so a pseudonym or
+		// score sent at the same moment would give its author away.
+		votes, err := s.beerRepo.countVotesOnBeer(ctx, vote.BeerId)
+		if err != nil {
+			return err
+		}
+		cMessage = s.centrifugo.CreateVoteCountMessage(vote.BeerId, votes, "vote-updated")
+	case policy.published:
+		cMessage = s.centrifugo.CreateVoteMessage(vote.BeerId, vote.UserId, vote.Value, note, vote.UserName, "vote-updated")
+	default:
+		cMessage = s.centrifugo.CreateVotedMessage(vote.BeerId, vote.UserId, vote.UserName, "vote-updated")
+	}
+	s.centrifugo.HandleMessage(ctx, cMessage)
+
+	switch {
+	case policy.anonymity != AnonymityOff:
+		cMessage := s.centrifugo.CreateHostsVotedMessage(policy.roomId, vote.BeerId, vote.UserId, vote.UserName, "vote-updated")
+		s.centrifugo.HandleMessage(ctx, cMessage)
+	case !policy.published && policy.visible(true):
+		cMessage := s.centrifugo.CreateHostsVoteMessage(
+			policy.roomId,
+			vote.BeerId,
+			vote.UserId,
+			vote.Value,
+			note,
+			vote.UserName,
+			"vote-updated",
+		)
+		s.centrifugo.HandleMessage(ctx, cMessage)
+	}
+	return nil
+}
+
+func (br *BeerRepo) countVotesOnBeer(ctx context.Context, beerId int) (int, error) {
+	var votes int
+	err := br.db.QueryRowContext(ctx, `
+      SELECT COUNT(*)
+      FROM votes
+      WHERE beer_id = ?
+    `,
+		beerId,
+	).Scan(&votes)
+	return votes, err
+}
@@ -0,0 +1,366 @@
+package beers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	VotingCodeClosed         = "voting-closed"
+	VotingCodeDeadlinePassed = "deadline-passed"
+	VotingCodeFrozen         = "votes-frozen"
+)
+
+// closeReason records why voting on a beer was closed. Voting is only
+// reopened automatically for the reason that closed it.
+type closeReason string
+
+const (
+	closedByHost     closeReason = "host"
+	closedByDeadline closeReason = "deadline"
+	closedByPublish  closeReason = "publish"
+)
+
+// votingState is everything that decides whether a beer accepts votes.
+type votingState struct {
+	roomId       int
+	published    bool
+	freeze       bool
+	closed       bool
+	closedBy     closeReason
+	beerDeadline *time.Time
+	roomDeadline *time.Time
+}
+
+// check returns why the beer does not accept votes at the given time, or
+// nil when voting is open.
+func (v votingState) check(now time.Time) error {
+	if v.published && v.freeze {
+		return VotingClosedError{Code: VotingCodeFrozen, ErrorInfo: "Votes are frozen once ratings are published"}
+	}
+	if v.closed {
+		return VotingClosedError{Code: VotingCodeClosed, ErrorInfo: "Voting is closed for this beer"}
+	}
+	for _, deadline := range []*time.Time{v.beerDeadline, v.roomDeadline} {
+		if deadline != nil && !now.Before(*deadline) {
+			return VotingClosedError{Code: VotingCodeDeadlinePassed, ErrorInfo: "The voting deadline has passed"}
+		}
+	}
+	return nil
+}
+
+func (br *BeerRepo) getVotingState(ctx context.Context, beerId int) (votingState, error) {
+	var v votingState
+	err := br.db.QueryRowContext(ctx, `
+      SELECT
+        rooms.id,
+        beers.published,
+        rooms.freeze_votes_on_publish,
+        beers.voting_closed,
+        beers.voting_closed_reason,
+        beers.voting_deadline,
+        rooms.voting_deadline
+      FROM beers
+      JOIN rooms ON rooms.id = beers.room_id
+      WHERE beers.id = ?
+    `,
+		beerId,
+	).Scan(&v.roomId, &v.published, &v.freeze, &v.closed, &v.closedBy, &v.beerDeadline, &v.roomDeadline)
+	return v, err
+}
+
+// setVotingClosed reports whether the beer's state actually changed, so
+// only one caller announces it. A beer that is already closed keeps the
+// reason it was first closed for.
+func (br *BeerRepo) setVotingClosed(ctx context.Context, beerId int, roomId int, closed bool, reason closeReason) (bool, error) {
+	if !closed {
+		reason = ""
+	}
+	res, err := br.db.ExecContext(ctx, `
+      UPDATE beers SET voting_closed = ?, voting_closed_reason = ?
+      WHERE id = ? AND room_id = ? AND voting_closed != ?
+    `,
+		closed,
+		reason,
+		beerId,
+		roomId,
+		closed,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// reopenVoting reopens the beer only if it was closed for the reason given.
+func (br *BeerRepo) reopenVoting(ctx context.Context, beerId int, roomId int, reason closeReason) (bool, error) {
+	res, err := br.db.ExecContext(ctx, `
+      UPDATE beers SET voting_closed = 0, voting_closed_reason = ''
+      WHERE id = ? AND room_id = ? AND voting_closed = 1 AND voting_closed_reason = ?
+    `,
+		beerId,
+		roomId,
+		reason,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (br *BeerRepo) getBeersClosedBy(ctx context.Context, roomId int, reason closeReason) ([]int, error) {
+	rows, err := br.db.QueryContext(ctx, `
+      SELECT id
+      FROM beers
+      WHERE room_id = ? AND voting_closed = 1 AND voting_closed_reason = ?
+    `,
+		roomId,
+		reason,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (br *BeerRepo) setVotingDeadline(ctx context.Context, beerId int, roomId int, deadline *time.Time) error {
+	_, err := br.db.ExecContext(ctx, `
+      UPDATE beers SET voting_deadline = ?
+      WHERE id = ? AND room_id = ?
+    `,
+		deadline,
+		beerId,
+		roomId,
+	)
+	return err
+}
+
+type expiredBeer struct {
+	beerId      int
+	roomId      int
+	autoPublish bool
+}
+
+func (br *BeerRepo) getExpiredOpenBeers(ctx context.Context, now time.Time) ([]expiredBeer, error) {
+	rows, err := br.db.QueryContext(ctx, `
+      SELECT
+        beers.id,
+        beers.room_id,
+        rooms.auto_publish AND rooms.state IN ('open', 'live') AND NOT beers.auto_published
+      FROM beers
+      JOIN rooms ON rooms.id = beers.room_id
+      WHERE beers.voting_closed = 0
+      AND (
+        beers.voting_deadline <= ?
+        OR rooms.voting_deadline <= ?
+        OR (
+          beers.auto_publish_at <= ?
+          AND rooms.auto_publish AND rooms.state IN ('open', 'live') AND NOT beers.auto_published
+        )
+      )
+    `,
+		now,
+		now,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expired := []expiredBeer{}
+	for rows.Next() {
+		var b expiredBeer
+		if err := rows.Scan(&b.beerId, &b.roomId, &b.autoPublish); err != nil {
+			return nil, err
+		}
+		expired = append(expired, b)
+	}
+	return expired, rows.Err()
+}
+
+// checkVotingOpen refuses votes on beers whose voting is closed, whose
+// deadline has passed, or whose published ratings are frozen.
+func (s *BeerService) checkVotingOpen(ctx context.Context, beerId int) error {
+	state, err := s.beerRepo.getVotingState(ctx, beerId)
+	if err != nil {
+		return err
+	}
+	return state.check(time.Now())
+}
+
+func (s *BeerService) announceVoting(ctx context.Context, beerId int, roomId int, closed bool) {
+	reason := "voting-opened"
+	if closed {
+		reason = "voting-closed"
+	}
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, reason, map[string]string{
+		"beerId": strconv.Itoa(beerId),
+	})
+	s.centrifugo.HandleMessage(ctx, cMessage)
+}
+
+func (s *BeerService) setVotingClosed(ctx context.Context, beerId int, roomId int, closed bool, reason closeReason) error {
+	changed, err := s.beerRepo.setVotingClosed(ctx, beerId, roomId, closed, reason)
+	if err != nil {
+		return err
+	}
+	if changed {
+		s.announceVoting(ctx, beerId, roomId, closed)
+	}
+	return nil
+}
+
+func (s *BeerService) reopenVoting(ctx context.Context, beerId int, roomId int, reason closeReason) error {
+	changed, err := s.beerRepo.reopenVoting(ctx, beerId, roomId, reason)
+	if err != nil {
+		return err
+	}
+	if changed {
+		s.announceVoting(ctx, beerId, roomId, false)
+	}
+	return nil
+}
+
+func (s *BeerService) CloseVoting(ctx context.Context, beerId int, roomId int) error {
+	return s.setVotingClosed(ctx, beerId, roomId, true, closedByHost)
+}
+
+// OpenVoting reopens a beer for votes, dropping its own deadline if that
+// has already passed. A passed room deadline still applies.
+func (s *BeerService) OpenVoting(ctx context.Context, beerId int, roomId int) error {
+	state, err := s.beerRepo.getVotingState(ctx, beerId)
+	if err != nil {
+		return err
+	}
+	if state.beerDeadline != nil && !time.Now().Before(*state.beerDeadline) {
+		if err := s.beerRepo.setVotingDeadline(ctx, beerId, roomId, nil); err != nil {
+			return err
+		}
+	}
+	return s.setVotingClosed(ctx, beerId, roomId, false, "")
+}
+
+// SetVotingDeadline sets or, with a nil deadline, clears when voting on the
+// beer closes. A beer that was closed by a deadline reopens once no
+// deadline has passed any more.
+func (s *BeerService) SetVotingDeadline(ctx context.Context, beerId int, roomId int, deadline *time.Time) error {
+	if err := s.beerRepo.setVotingDeadline(ctx, beerId, roomId, deadline); err != nil {
+		return err
+	}
+	s.announceDeadline(ctx, beerId, roomId, deadline)
+	return s.reopenIfDeadlineMoved(ctx, beerId, roomId)
+}
+
+// ReopenAfterRoomDeadline reopens the room's beers that its deadline closed,
+// once the deadline has been moved or cleared.
+func (s *BeerService) ReopenAfterRoomDeadline(ctx context.Context, roomId int) error {
+	ids, err := s.beerRepo.getBeersClosedBy(ctx, roomId, closedByDeadline)
+	if err != nil {
+		return err
+	}
+	for _, beerId := range ids {
+		if err := s.reopenIfDeadlineMoved(ctx, beerId, roomId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BeerService) reopenIfDeadlineMoved(ctx context.Context, beerId int, roomId int) error {
+	state, err := s.beerRepo.getVotingState(ctx, beerId)
+	if err != nil {
+		return err
+	}
+	if !state.closed || state.closedBy != closedByDeadline {
+		return nil
+	}
+	state.closed = false
+	if state.check(time.Now()) != nil {
+		return nil
+	}
+	return s.reopenVoting(ctx, beerId, roomId, closedByDeadline)
+}
+
+func (s *BeerService) announceDeadline(ctx context.Context, beerId int, roomId int, deadline *time.Time) {
+	payload := map[string]string{
+		"beerId":   strconv.Itoa(beerId),
+		"deadline": "",
+	}
+	if deadline != nil {
+		payload["deadline"] = deadline.UTC().Format(time.RFC3339)
+	}
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "voting-deadline-updated", payload)
+	s.centrifugo.HandleMessage(ctx, cMessage)
+}
+
+// CloseExpiredVoting closes voting on every beer whose own or room deadline
+// has passed and announces it. Rooms that auto-publish get the ratings
+// published instead, the first time a beer expires or its auto-publish
+// timeout runs out; their ids are returned.
+// A beer that fails is logged and retried on the next call, without holding
+// up the others.
+func (s *BeerService) CloseExpiredVoting(ctx context.Context) ([]int, error) {
+	expired, err := s.beerRepo.getExpiredOpenBeers(ctx, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	published := []int{}
+	var errs []error
+	for _, b := range expired {
+		if b.autoPublish {
+			ok, err := s.autoPublish(ctx, b.beerId, b.roomId)
+			if err != nil {
+				s.logger.Error("CloseExpiredVoting/publish", "err", err, "beerId", b.beerId)
+				errs = append(errs, err)
+				continue
+			}
+			if ok {
+				published = append(published, b.beerId)
+			}
+			continue
+		}
+		if err := s.setVotingClosed(ctx, b.beerId, b.roomId, true, closedByDeadline); err != nil {
+			s.logger.Error("CloseExpiredVoting/close", "err", err, "beerId", b.beerId)
+			errs = append(errs, err)
+		}
+	}
+	return published, errors.Join(errs...)
+}
+
+// WatchVotingDeadlines closes expired voting every interval until ctx is
+// done. Votes are refused as soon as a deadline passes either way; the
+// watcher makes sure the room hears about it. onPublish is called for every
+// beer the watcher publishes.
+func (s *BeerService) WatchVotingDeadlines(ctx context.Context, interval time.Duration, onPublish func(ctx context.Context, beerId int)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			published, err := s.CloseExpiredVoting(ctx)
+			if err != nil {
+				s.logger.Error("WatchVotingDeadlines", "err", err)
+			}
+			for _, beerId := range published {
+				onPublish(ctx, beerId)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package flavors
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+//go:embed descriptors.json
+var defaultDescriptors []byte
+
+type Descriptor struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+}
+
+type Category struct {
+	Id          string       `json:"id"`
+	Name        string       `json:"name"`
+	Descriptors []Descriptor `json:"descriptors"`
+}
+
+type Taxonomy struct {
+	Categories []Category `json:"categories"`
+
+	byId map[string]Descriptor
+}
+
+// Load reads a taxonomy in the same format as descriptors.json. Descriptor
+// ids have to be unique across all categories.
+func Load(r io.Reader) (*Taxonomy, error) {
+	var t Taxonomy
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, err
+	}
+
+	t.byId = map[string]Descriptor{}
+	for ci, c := range t.Categories {
+		for di, d := range c.Descriptors {
+			if d.Id == "" {
+				return nil, fmt.Errorf("descriptor without id in category %q", c.Id)
+			}
+			if _, ok := t.byId[d.Id]; ok {
+				return nil, fmt.Errorf("duplicate descriptor %q", d.Id)
+			}
+			d.Category = c.Id
+			t.Categories[ci].Descriptors[di] = d
+			t.byId[d.Id] = d
+		}
+	}
+	return &t, nil
+}
+
+// LoadFile loads the taxonomy from path, or the built-in taxonomy when path
+// is empty.
+func LoadFile(path string) (*Taxonomy, error) {
+	if path == "" {
+		return Load(bytes.NewReader(defaultDescriptors))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+func (t *Taxonomy) Lookup(id string) (Descriptor, bool) {
+	d, ok := t.byId[id]
+	return d, ok
+}
@@ -0,0 +1,17 @@
+package guesses
+
+type InvalidGuessError struct {
+	ErrorInfo string
+}
+
+func (e InvalidGuessError) Error() string {
+	return e.ErrorInfo
+}
+
+type ClosedError struct {
+	ErrorInfo string
+}
+
+func (e ClosedError) Error() string {
+	return e.ErrorInfo
+}
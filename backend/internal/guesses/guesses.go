@@ -0,0 +1,269 @@
+package guesses
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+)
+
+type GuessRepo struct {
+	db *sql.DB
+}
+
+type Guess struct {
+	Id     int      `json:"id"`
+	BeerId int      `json:"beerId"`
+	UserId int      `json:"userId"`
+	Field  Field    `json:"field"`
+	Value  string   `json:"value"`
+	Score  *float64 `json:"score"`
+}
+
+type LeaderboardEntry struct {
+	UserId   int     `json:"userId"`
+	UserName string  `json:"name"`
+	Score    float64 `json:"score"`
+	Guesses  int     `json:"guesses"`
+	Scored   int     `json:"scored"`
+}
+
+// answeredGuess is a guess on a revealed beer together with the answer for
+// its field.
+type answeredGuess struct {
+	id     int
+	field  Field
+	value  string
+	answer string
+}
+
+func NewGuessRepo(db *sql.DB) *GuessRepo {
+	return &GuessRepo{
+		db: db,
+	}
+}
+
+func (gr *GuessRepo) getRulesForRoom(ctx context.Context, roomId int) (Rules, error) {
+	rows, err := gr.db.QueryContext(ctx,
+		`
+      SELECT field, mode, points, tolerance
+      FROM room_guess_rules
+      WHERE room_id = ?
+    `,
+		roomId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := Rules{}
+	for rows.Next() {
+		var r Rule
+		err := rows.Scan(&r.Field, &r.Mode, &r.Points, &r.Tolerance)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func (gr *GuessRepo) replaceRules(ctx context.Context, roomId int, rules Rules) error {
+	tx, err := gr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+      DELETE FROM room_guess_rules
+      WHERE room_id = ?
+    `,
+		roomId,
+	)
+	if err != nil {
+		return err
+	}
+	for _, r := range rules {
+		_, err = tx.ExecContext(ctx, `
+        INSERT INTO room_guess_rules (room_id, field, mode, points, tolerance)
+        VALUES (?, ?, ?, ?, ?)
+      `,
+			roomId,
+			r.Field,
+			r.Mode,
+			r.Points,
+			r.Tolerance,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (gr *GuessRepo) isBeerRevealed(ctx context.Context, beerId int, roomId int) (bool, error) {
+	var revealed bool
+	err := gr.db.QueryRowContext(ctx, `
+      SELECT revealed
+      FROM beers
+      WHERE id = ? AND room_id = ?
+    `,
+		beerId,
+		roomId,
+	).Scan(&revealed)
+	return revealed, err
+}
+
+func (gr *GuessRepo) saveGuesses(ctx context.Context, beerId int, userId int, guesses []Guess) error {
+	tx, err := gr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, g := range guesses {
+		_, err = tx.ExecContext(ctx, `
+        INSERT INTO guesses (beer_id, user_id, field, value)
+        VALUES (?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE value = VALUES(value), score = NULL
+      `,
+			beerId,
+			userId,
+			g.Field,
+			g.Value,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (gr *GuessRepo) getGuessesByUser(ctx context.Context, beerId int, userId int) ([]Guess, error) {
+	rows, err := gr.db.QueryContext(ctx, `
+      SELECT id, beer_id, user_id, field, value, score
+      FROM guesses
+      WHERE beer_id = ? AND user_id = ?
+      ORDER BY field
+    `,
+		beerId,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guesses := []Guess{}
+	for rows.Next() {
+		var g Guess
+		err := rows.Scan(&g.Id, &g.BeerId, &g.UserId, &g.Field, &g.Value, &g.Score)
+		if err != nil {
+			return nil, err
+		}
+		guesses = append(guesses, g)
+	}
+	return guesses, rows.Err()
+}
+
+func (gr *GuessRepo) getRevealedGuessesInRoom(ctx context.Context, roomId int) ([]answeredGuess, error) {
+	rows, err := gr.db.QueryContext(ctx, `
+      SELECT guesses.id, guesses.field, guesses.value, beers.style, beers.brewery, beers.abv
+      FROM guesses
+      JOIN beers ON beers.id = guesses.beer_id
+      WHERE beers.room_id = ? AND beers.revealed = TRUE
+    `,
+		roomId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answered := []answeredGuess{}
+	for rows.Next() {
+		var (
+			g       answeredGuess
+			style   sql.NullString
+			brewery sql.NullString
+			abv     sql.NullFloat64
+		)
+		err := rows.Scan(&g.id, &g.field, &g.value, &style, &brewery, &abv)
+		if err != nil {
+			return nil, err
+		}
+		switch g.field {
+		case FieldStyle:
+			g.answer = style.String
+		case FieldBrewery:
+			g.answer = brewery.String
+		case FieldAbv:
+			if abv.Valid {
+				g.answer = strconv.FormatFloat(abv.Float64, 'f', -1, 64)
+			}
+		}
+		answered = append(answered, g)
+	}
+	return answered, rows.Err()
+}
+
+func (gr *GuessRepo) updateScores(ctx context.Context, scores map[int]float64) error {
+	tx, err := gr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, score := range scores {
+		_, err = tx.ExecContext(ctx, `
+        UPDATE guesses
+        SET score = ?
+        WHERE id = ?
+      `,
+			score,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (gr *GuessRepo) getLeaderboard(ctx context.Context, roomId int) ([]LeaderboardEntry, error) {
+	rows, err := gr.db.QueryContext(ctx, `
+      SELECT
+        users.id,
+        IF(users.name != '', users.name, users.username) AS userName,
+        COALESCE(SUM(guesses.score), 0) AS total,
+        COUNT(guesses.id),
+        COUNT(guesses.score)
+      FROM guesses
+      JOIN beers ON beers.id = guesses.beer_id
+      JOIN users ON users.id = guesses.user_id
+      WHERE beers.room_id = ?
+      GROUP BY users.id
+      ORDER BY total DESC, userName ASC
+    `,
+		roomId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LeaderboardEntry{}
+	for rows.Next() {
+		var e LeaderboardEntry
+		err := rows.Scan(&e.UserId, &e.UserName, &e.Score, &e.Guesses, &e.Scored)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
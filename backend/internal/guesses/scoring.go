@@ -0,0 +1,200 @@
+package guesses
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"skafteresort.se/beers/internal/numeric"
+)
+
+type Field string
+
+const (
+	FieldStyle   Field = "style"
+	FieldBrewery Field = "brewery"
+	FieldAbv     Field = "abv"
+)
+
+func (f Field) Valid() bool {
+	switch f {
+	case FieldStyle, FieldBrewery, FieldAbv:
+		return true
+	}
+	return false
+}
+
+type Mode string
+
+const (
+	// ModeExact awards full points when the normalized guess equals the
+	// answer.
+	ModeExact Mode = "exact"
+	// ModeFuzzy awards full points when the similarity between guess and
+	// answer is at least the rule's tolerance, a ratio between 0 and 1.
+	ModeFuzzy Mode = "fuzzy"
+	// ModeNumeric awards points falling off linearly with the distance to
+	// the answer, reaching zero at the rule's tolerance.
+	ModeNumeric Mode = "numeric"
+)
+
+type Rule struct {
+	Field     Field   `json:"field"`
+	Mode      Mode    `json:"mode"`
+	Points    float64 `json:"points"`
+	Tolerance float64 `json:"tolerance"`
+}
+
+type Rules []Rule
+
+// DefaultRules are used for every field a room has not configured.
+func DefaultRules() Rules {
+	return Rules{
+		{Field: FieldStyle, Mode: ModeFuzzy, Points: 1, Tolerance: 0.8},
+		{Field: FieldBrewery, Mode: ModeFuzzy, Points: 1, Tolerance: 0.8},
+		{Field: FieldAbv, Mode: ModeNumeric, Points: 1, Tolerance: 1},
+	}
+}
+
+func (r Rule) Valid() error {
+	if !r.Field.Valid() {
+		return InvalidGuessError{ErrorInfo: fmt.Sprintf("Unknown field %q", r.Field)}
+	}
+	if r.Points < 0 || r.Points > 100 {
+		return InvalidGuessError{ErrorInfo: "Points must be between 0 and 100"}
+	}
+	switch r.Mode {
+	case ModeExact:
+	case ModeFuzzy:
+		if r.Tolerance <= 0 || r.Tolerance > 1 {
+			return InvalidGuessError{ErrorInfo: "Fuzzy tolerance must be between 0 and 1"}
+		}
+	case ModeNumeric:
+		if r.Field != FieldAbv {
+			return InvalidGuessError{ErrorInfo: "Numeric scoring only applies to abv"}
+		}
+		if r.Tolerance <= 0 {
+			return InvalidGuessError{ErrorInfo: "Numeric tolerance must be greater than 0"}
+		}
+	default:
+		return InvalidGuessError{ErrorInfo: fmt.Sprintf("Unknown mode %q", r.Mode)}
+	}
+	return nil
+}
+
+func (rs Rules) Valid() error {
+	seen := map[Field]bool{}
+	for _, r := range rs {
+		if err := r.Valid(); err != nil {
+			return err
+		}
+		if seen[r.Field] {
+			return InvalidGuessError{ErrorInfo: fmt.Sprintf("Duplicate rule for %q", r.Field)}
+		}
+		seen[r.Field] = true
+	}
+	return nil
+}
+
+// merge returns the default rules with any configured ones replacing them.
+func (rs Rules) merge() Rules {
+	merged := DefaultRules()
+	for _, r := range rs {
+		for i := range merged {
+			if merged[i].Field == r.Field {
+				merged[i] = r
+			}
+		}
+	}
+	return merged
+}
+
+func (rs Rules) forField(f Field) (Rule, bool) {
+	for _, r := range rs {
+		if r.Field == f {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Score rates a guess against the answer. A missing answer scores nothing.
+func (r Rule) Score(guess string, answer string) float64 {
+	if answer == "" {
+		return 0
+	}
+	switch r.Mode {
+	case ModeExact:
+		if normalize(guess) == normalize(answer) {
+			return r.Points
+		}
+	case ModeFuzzy:
+		if similarity(normalize(guess), normalize(answer)) >= r.Tolerance {
+			return r.Points
+		}
+	case ModeNumeric:
+		g, err := strconv.ParseFloat(strings.TrimSpace(guess), 64)
+		if err != nil {
+			return 0
+		}
+		a, err := strconv.ParseFloat(answer, 64)
+		if err != nil {
+			return 0
+		}
+		return numeric.Round2(r.Points * math.Max(0, 1-math.Abs(g-a)/r.Tolerance))
+	}
+	return 0
+}
+
+// normalize lowercases and drops punctuation so "Brewdog" and "BrewDog!"
+// compare equal.
+func normalize(s string) string {
+	var b strings.Builder
+	space := false
+	for _, c := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			space = false
+			b.WriteRune(c)
+		case unicode.IsSpace(c) || c == '-' || c == '_':
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// similarity is one minus the Levenshtein distance relative to the longer
+// string, so identical strings score 1.
+func similarity(a string, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
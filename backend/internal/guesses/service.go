@@ -0,0 +1,131 @@
+package guesses
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"skafteresort.se/beers/internal/providers"
+)
+
+const maxGuessLength = 255
+
+type GuessService struct {
+	guessRepo  *GuessRepo
+	logger     *slog.Logger
+	centrifugo *providers.CentrifugoProvider
+}
+
+func NewGuessService(
+	gr *GuessRepo,
+	logger *slog.Logger,
+	gp *providers.CentrifugoProvider,
+) *GuessService {
+	gs := GuessService{
+		guessRepo:  gr,
+		logger:     logger,
+		centrifugo: gp,
+	}
+	return &gs
+}
+
+// GetRules returns the room's rule for every field, falling back to the
+// defaults for fields that have not been configured.
+func (s *GuessService) GetRules(ctx context.Context, roomId int) (Rules, error) {
+	rules, err := s.guessRepo.getRulesForRoom(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	return rules.merge(), nil
+}
+
+// SetRules replaces the room's scoring rules and rescores guesses on beers
+// that have already been revealed.
+func (s *GuessService) SetRules(ctx context.Context, roomId int, rules Rules) error {
+	if err := rules.Valid(); err != nil {
+		return err
+	}
+	if err := s.guessRepo.replaceRules(ctx, roomId, rules); err != nil {
+		return err
+	}
+	return s.ScoreRoom(ctx, roomId)
+}
+
+// SubmitGuesses stores the user's guesses on a beer, replacing earlier
+// guesses on the same fields. Guessing closes when the beer is revealed.
+func (s *GuessService) SubmitGuesses(ctx context.Context, beerId int, roomId int, userId int, guesses []Guess) error {
+	seen := map[Field]bool{}
+	for i, g := range guesses {
+		if !g.Field.Valid() {
+			return InvalidGuessError{ErrorInfo: fmt.Sprintf("Unknown field %q", g.Field)}
+		}
+		if seen[g.Field] {
+			return InvalidGuessError{ErrorInfo: fmt.Sprintf("Duplicate guess for %q", g.Field)}
+		}
+		seen[g.Field] = true
+
+		value := strings.TrimSpace(g.Value)
+		if value == "" || len(value) > maxGuessLength {
+			return InvalidGuessError{ErrorInfo: fmt.Sprintf("Guess for %q must be 1-%d characters", g.Field, maxGuessLength)}
+		}
+		if g.Field == FieldAbv {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return InvalidGuessError{ErrorInfo: "Guess for \"abv\" must be a number"}
+			}
+		}
+		guesses[i].Value = value
+	}
+
+	revealed, err := s.guessRepo.isBeerRevealed(ctx, beerId, roomId)
+	if err != nil {
+		return err
+	}
+	if revealed {
+		return ClosedError{ErrorInfo: "Guessing is closed for revealed beers"}
+	}
+
+	return s.guessRepo.saveGuesses(ctx, beerId, userId, guesses)
+}
+
+func (s *GuessService) GetMyGuesses(ctx context.Context, beerId int, userId int) ([]Guess, error) {
+	return s.guessRepo.getGuessesByUser(ctx, beerId, userId)
+}
+
+func (s *GuessService) GetLeaderboard(ctx context.Context, roomId int) ([]LeaderboardEntry, error) {
+	return s.guessRepo.getLeaderboard(ctx, roomId)
+}
+
+// ScoreRoom scores every guess on the room's revealed beers with the current
+// rules and announces the new standings. Scoring everything keeps the
+// leaderboard right after rule changes and corrections to revealed beers.
+func (s *GuessService) ScoreRoom(ctx context.Context, roomId int) error {
+	rules, err := s.GetRules(ctx, roomId)
+	if err != nil {
+		return err
+	}
+	answered, err := s.guessRepo.getRevealedGuessesInRoom(ctx, roomId)
+	if err != nil {
+		return err
+	}
+	if len(answered) == 0 {
+		return nil
+	}
+
+	scores := make(map[int]float64, len(answered))
+	for _, g := range answered {
+		rule, ok := rules.forField(g.field)
+		if !ok {
+			continue
+		}
+		scores[g.id] = rule.Score(g.value, g.answer)
+	}
+	if err := s.guessRepo.updateScores(ctx, scores); err != nil {
+		return err
+	}
+
+	cMessage := s.centrifugo.CreateBeerMessage(roomId, "guess-scores-updated")
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
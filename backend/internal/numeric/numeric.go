@@ -0,0 +1,48 @@
+package numeric
+
+import (
+	"math"
+	"sort"
+)
+
+// Round2 rounds to two decimals, the precision every score and statistic
+// is reported in.
+func Round2(v float64) float64 {
+	r := math.Round(v*100) / 100
+	// Normalized scores are often tiny negatives that would otherwise
+	// round to -0.
+	if r == 0 {
+		return 0
+	}
+	return r
+}
+
+func Ptr(v float64) *float64 {
+	return &v
+}
+
+// Ranks replaces each value with its rank, giving tied values the average
+// of the ranks they span.
+func Ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return values[order[a]] < values[order[b]]
+	})
+
+	ranked := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			ranked[order[k]] = rank
+		}
+		i = j + 1
+	}
+	return ranked
+}
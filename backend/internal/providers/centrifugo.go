@@ -15,6 +15,12 @@ type Message struct {
 	Payload map[string]string
 }
 
+// HostsChannel is only open to the hosts of a room, see
+// handleSubscriptionToken.
+func HostsChannel(roomId int) string {
+	return fmt.Sprintf("beers:room-%d-hosts", roomId)
+}
+
 type CentrifugoProvider struct {
 	client *gocent.Client
 	logger *slog.Logger
@@ -34,7 +40,7 @@ func (p *CentrifugoProvider) HandleMessage(ctx context.Context, message Message)
 func (p *CentrifugoProvider) CreateVoteMessage(
 	beerId int,
 	userId int,
-	voteValue int,
+	voteValue float64,
 	voteNote string,
 	username string,
 	reason string,
@@ -43,13 +49,73 @@ func (p *CentrifugoProvider) CreateVoteMessage(
 	return p.CreateMessageWithChannel(c, map[string]string{
 		"beerId":    strconv.Itoa(beerId),
 		"userId":    strconv.Itoa(userId),
-		"voteValue": strconv.Itoa(voteValue),
+		"voteValue": strconv.FormatFloat(voteValue, 'f', -1, 64),
 		"voteNote":  voteNote,
 		"username":  username,
 		"reason":    reason,
 	})
 }
 
+// CreateVotedMessage announces that a user voted without telling what
+// they voted.
+func (p *CentrifugoProvider) CreateVotedMessage(
+	beerId int,
+	userId int,
+	username string,
+	reason string,
+) Message {
+	c := fmt.Sprintf("beers:beer-%d", beerId)
+	return p.CreateMessageWithChannel(c, map[string]string{
+		"beerId":   strconv.Itoa(beerId),
+		"userId":   strconv.Itoa(userId),
+		"username": username,
+		"reason":   reason,
+	})
+}
+
+// CreateVoteCountMessage announces how many votes the beer has, without
+// telling who cast them.
+func (p *CentrifugoProvider) CreateVoteCountMessage(
+	beerId int,
+	votes int,
+	reason string,
+) Message {
+	c := fmt.Sprintf("beers:beer-%d", beerId)
+	return p.CreateMessageWithChannel(c, map[string]string{
+		"beerId": strconv.Itoa(beerId),
+		"votes":  strconv.Itoa(votes),
+		"reason": reason,
+	})
+}
+
+// CreateHostsVoteMessage sends the full vote to the hosts of the room.
+func (p *CentrifugoProvider) CreateHostsVoteMessage(
+	roomId int,
+	beerId int,
+	userId int,
+	voteValue float64,
+	voteNote string,
+	username string,
+	reason string,
+) Message {
+	m := p.CreateVoteMessage(beerId, userId, voteValue, voteNote, username, reason)
+	m.Channel = HostsChannel(roomId)
+	return m
+}
+
+// CreateHostsVotedMessage tells the hosts of the room who voted.
+func (p *CentrifugoProvider) CreateHostsVotedMessage(
+	roomId int,
+	beerId int,
+	userId int,
+	username string,
+	reason string,
+) Message {
+	m := p.CreateVotedMessage(beerId, userId, username, reason)
+	m.Channel = HostsChannel(roomId)
+	return m
+}
+
 func (p *CentrifugoProvider) CreateBeerMessage(
 	roomId int,
 	reason string,
@@ -62,6 +128,29 @@ func (p *CentrifugoProvider) CreateBeerMessage(
 
 }
 
+func (p *CentrifugoProvider) CreateRoomMessage(
+	roomId int,
+	reason string,
+	payload map[string]string,
+) Message {
+	m := p.CreateBeerMessage(roomId, reason)
+	for k, v := range payload {
+		m.Payload[k] = v
+	}
+	return m
+}
+
+// CreateHostsMessage is CreateRoomMessage for the hosts channel.
+func (p *CentrifugoProvider) CreateHostsMessage(
+	roomId int,
+	reason string,
+	payload map[string]string,
+) Message {
+	m := p.CreateRoomMessage(roomId, reason, payload)
+	m.Channel = HostsChannel(roomId)
+	return m
+}
+
 func (p *CentrifugoProvider) CreateMessageWithChannel(channel string, payload map[string]string) Message {
 	return Message{
 		Channel: channel,
@@ -0,0 +1,113 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// maxImageBytes caps the size of a fetched picture.
+	maxImageBytes = 2 << 20
+	imageTimeout  = 5 * time.Second
+	maxRedirects  = 3
+)
+
+// Image is a fetched picture. Type is the format as fpdf names it: JPG,
+// PNG or GIF.
+type Image struct {
+	Data []byte
+	Type string
+}
+
+var imageTypes = map[string]string{
+	"image/jpeg": "JPG",
+	"image/png":  "PNG",
+	"image/gif":  "GIF",
+}
+
+// ImageProvider fetches beer pictures from a fixed list of hosts. Picture
+// URLs are supplied by users, so anything else would let them make the
+// server request arbitrary addresses, internal ones included.
+type ImageProvider struct {
+	client *http.Client
+	hosts  map[string]bool
+}
+
+func NewImageProvider(hosts []string) *ImageProvider {
+	p := &ImageProvider{hosts: map[string]bool{}}
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			p.hosts[h] = true
+		}
+	}
+	p.client = &http.Client{
+		Timeout: imageTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			return p.check(req.URL)
+		},
+	}
+	return p
+}
+
+// Enabled reports whether any host is allowed.
+func (p *ImageProvider) Enabled() bool {
+	return len(p.hosts) > 0
+}
+
+func (p *ImageProvider) check(u *url.URL) error {
+	if u.Scheme != "https" {
+		return fmt.Errorf("image url must use https: %s", u.Redacted())
+	}
+	if !p.hosts[strings.ToLower(u.Hostname())] {
+		return fmt.Errorf("image host is not allowed: %s", u.Hostname())
+	}
+	return nil
+}
+
+// Fetch downloads a picture from an allowed host. Pictures larger than
+// maxImageBytes, or in formats other than JPEG, PNG and GIF, are refused.
+func (p *ImageProvider) Fetch(ctx context.Context, rawUrl string) (*Image, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.check(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image fetch failed with status %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageBytes {
+		return nil, errors.New("image is too large")
+	}
+	// The content is sniffed rather than trusting the declared type.
+	imageType, ok := imageTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, errors.New("image format is not supported")
+	}
+	return &Image{Data: data, Type: imageType}, nil
+}
@@ -0,0 +1,195 @@
+package recommendations
+
+import (
+	"math"
+	"sync"
+)
+
+// index holds every visible rating by item and the running sums needed for
+// the cosine similarity between each pair of items, so a changed rating
+// only touches the pairs involving the rater's other items.
+type index struct {
+	mu     sync.RWMutex
+	loaded bool
+
+	items map[string]*item
+	// beers maps each indexed beer to its item and room, so a beer that is
+	// renamed, hidden or deleted can be taken out again.
+	beers     map[int]indexedBeer
+	userItems map[int]map[string]float64
+	pairs     map[pairKey]*pairStats
+}
+
+type item struct {
+	name  string
+	style *string
+	// votes holds each user's ratings per beer, since the same item can be
+	// rated in several rooms.
+	votes map[int]map[int]float64
+}
+
+type indexedBeer struct {
+	key    string
+	roomId int
+}
+
+type pairKey struct {
+	a, b string
+}
+
+type pairStats struct {
+	dot, normA, normB float64
+	raters            int
+}
+
+func newIndex() *index {
+	return &index{
+		items:     map[string]*item{},
+		beers:     map[int]indexedBeer{},
+		userItems: map[int]map[string]float64{},
+		pairs:     map[pairKey]*pairStats{},
+	}
+}
+
+// minCoRaters is how many users must have rated both items before their
+// similarity is trusted.
+const minCoRaters = 2
+
+// centered shifts a 0-1 rating so that average ratings contribute nothing
+// and disliking both items counts as agreement.
+func centered(r float64) float64 {
+	return r - 0.5
+}
+
+func orderedPair(a string, b string) (pairKey, bool) {
+	if a < b {
+		return pairKey{a, b}, false
+	}
+	return pairKey{b, a}, true
+}
+
+// similarity is the cosine similarity of two items over their co-raters.
+func (ix *index) similarity(a string, b string) (float64, bool) {
+	key, _ := orderedPair(a, b)
+	p, ok := ix.pairs[key]
+	if !ok || p.raters < minCoRaters || p.normA == 0 || p.normB == 0 {
+		return 0, false
+	}
+	return p.dot / math.Sqrt(p.normA*p.normB), true
+}
+
+// setRating changes one user's rating of an item, or removes it when
+// rating is nil, and updates the pair sums with the user's other items.
+func (ix *index) setRating(userId int, key string, rating *float64) {
+	ratings, ok := ix.userItems[userId]
+	if !ok {
+		ratings = map[string]float64{}
+		ix.userItems[userId] = ratings
+	}
+	old, hadOld := ratings[key]
+
+	for other, r := range ratings {
+		if other == key {
+			continue
+		}
+		pk, swapped := orderedPair(key, other)
+		p, ok := ix.pairs[pk]
+		if !ok {
+			p = &pairStats{}
+			ix.pairs[pk] = p
+		}
+		if hadOld {
+			p.add(centered(old), centered(r), swapped, -1)
+			p.raters--
+		}
+		if rating != nil {
+			p.add(centered(*rating), centered(r), swapped, 1)
+			p.raters++
+		}
+		if p.raters == 0 {
+			delete(ix.pairs, pk)
+		}
+	}
+
+	if rating == nil {
+		delete(ratings, key)
+	} else {
+		ratings[key] = *rating
+	}
+}
+
+// add applies one co-rating, where x rates the first item given to
+// orderedPair and y the other one.
+func (p *pairStats) add(x float64, y float64, swapped bool, sign float64) {
+	if swapped {
+		x, y = y, x
+	}
+	p.dot += sign * x * y
+	p.normA += sign * x * x
+	p.normB += sign * y * y
+}
+
+// removeBeer takes a beer's ratings out of its item.
+func (ix *index) removeBeer(beerId int) {
+	b, ok := ix.beers[beerId]
+	if !ok {
+		return
+	}
+	delete(ix.beers, beerId)
+	it := ix.items[b.key]
+	for userId, beers := range it.votes {
+		if _, ok := beers[beerId]; !ok {
+			continue
+		}
+		delete(beers, beerId)
+		if len(beers) == 0 {
+			delete(it.votes, userId)
+		}
+		ix.setRating(userId, b.key, average(beers))
+	}
+	if len(it.votes) == 0 {
+		delete(ix.items, b.key)
+	}
+}
+
+// applyBeer replaces everything indexed for a beer with its current state.
+func (ix *index) applyBeer(b *beerVotes) {
+	ix.removeBeer(b.id)
+	if !b.visible || len(b.ratings) == 0 {
+		return
+	}
+
+	key := itemKey(b.name)
+	it, ok := ix.items[key]
+	if !ok {
+		it = &item{votes: map[int]map[int]float64{}}
+		ix.items[key] = it
+	}
+	it.name = b.name
+	if b.style != nil {
+		it.style = b.style
+	}
+	ix.beers[b.id] = indexedBeer{key: key, roomId: b.roomId}
+
+	for userId, r := range b.ratings {
+		beers, ok := it.votes[userId]
+		if !ok {
+			beers = map[int]float64{}
+			it.votes[userId] = beers
+		}
+		beers[b.id] = r
+		ix.setRating(userId, key, average(beers))
+	}
+}
+
+func average(values map[int]float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	avg := sum / float64(len(values))
+	return &avg
+}
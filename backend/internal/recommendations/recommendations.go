@@ -0,0 +1,144 @@
+package recommendations
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+type RecommendationRepo struct {
+	db *sql.DB
+}
+
+// beerVotes is a beer with the normalized ratings visible on it. Ratings
+// are only visible once the beer is published and, in blind rooms,
+// revealed.
+type beerVotes struct {
+	id      int
+	roomId  int
+	name    string
+	style   *string
+	visible bool
+	ratings map[int]float64
+}
+
+func NewRecommendationRepo(db *sql.DB) *RecommendationRepo {
+	return &RecommendationRepo{
+		db: db,
+	}
+}
+
+// itemKey groups the same beer tasted in different rooms.
+func itemKey(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+const beerVotesQuery = `
+  SELECT
+    beers.id,
+    beers.room_id,
+    beers.name,
+    beers.style,
+    beers.published = TRUE AND (rooms.blind_mode = FALSE OR beers.revealed = TRUE),
+    votes.user_id,
+    (votes.points - rooms.scale_min) / (rooms.scale_max - rooms.scale_min)
+  FROM beers
+  JOIN rooms ON rooms.id = beers.room_id
+  LEFT JOIN votes ON votes.beer_id = beers.id
+`
+
+func (rr *RecommendationRepo) queryBeerVotes(ctx context.Context, where string, args ...any) ([]*beerVotes, error) {
+	rows, err := rr.db.QueryContext(ctx, beerVotesQuery+where+" ORDER BY beers.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	beers := []*beerVotes{}
+	var current *beerVotes
+	for rows.Next() {
+		var (
+			b      beerVotes
+			userId sql.NullInt64
+			rating sql.NullFloat64
+		)
+		err := rows.Scan(&b.id, &b.roomId, &b.name, &b.style, &b.visible, &userId, &rating)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || current.id != b.id {
+			b.ratings = map[int]float64{}
+			current = &b
+			beers = append(beers, current)
+		}
+		if userId.Valid && rating.Valid {
+			current.ratings[int(userId.Int64)] = rating.Float64
+		}
+	}
+	return beers, rows.Err()
+}
+
+func (rr *RecommendationRepo) getAllBeerVotes(ctx context.Context) ([]*beerVotes, error) {
+	return rr.queryBeerVotes(ctx, "")
+}
+
+func (rr *RecommendationRepo) getBeerVotes(ctx context.Context, beerId int) ([]*beerVotes, error) {
+	return rr.queryBeerVotes(ctx, "WHERE beers.id = ?", beerId)
+}
+
+func (rr *RecommendationRepo) getBeerVotesInRoom(ctx context.Context, roomId int) ([]*beerVotes, error) {
+	return rr.queryBeerVotes(ctx, "WHERE beers.room_id = ?", roomId)
+}
+
+// getCohort returns everyone who shares at least one room with the user.
+func (rr *RecommendationRepo) getCohort(ctx context.Context, userId int) (map[int]bool, error) {
+	rows, err := rr.db.QueryContext(ctx, `
+      SELECT DISTINCT other.user_id
+      FROM user_room AS mine
+      JOIN user_room AS other ON other.room_id = mine.room_id
+      WHERE mine.user_id = ? AND other.user_id != mine.user_id
+    `,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cohort := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		cohort[id] = true
+	}
+	return cohort, rows.Err()
+}
+
+// getTastedItems returns every beer the user has rated, including ones
+// whose ratings are not visible yet, so they are never recommended.
+func (rr *RecommendationRepo) getTastedItems(ctx context.Context, userId int) (map[string]bool, error) {
+	rows, err := rr.db.QueryContext(ctx, `
+      SELECT DISTINCT beers.name
+      FROM votes
+      JOIN beers ON beers.id = votes.beer_id
+      WHERE votes.user_id = ?
+    `,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasted := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tasted[itemKey(name)] = true
+	}
+	return tasted, rows.Err()
+}
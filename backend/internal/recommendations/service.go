@@ -0,0 +1,259 @@
+package recommendations
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"skafteresort.se/beers/internal/numeric"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 50
+
+	// likedThreshold is the normalized rating from which a beer counts as
+	// liked when explaining a recommendation.
+	likedThreshold = 0.6
+)
+
+const (
+	SourceSimilar = "similar"
+	SourceStyle   = "style"
+)
+
+type RecommendationService struct {
+	recommendationRepo *RecommendationRepo
+	logger             *slog.Logger
+	index              *index
+}
+
+func NewRecommendationService(rr *RecommendationRepo, logger *slog.Logger) *RecommendationService {
+	rs := RecommendationService{
+		recommendationRepo: rr,
+		logger:             logger,
+		index:              newIndex(),
+	}
+	return &rs
+}
+
+type Recommendation struct {
+	Name  string  `json:"name"`
+	Style *string `json:"style"`
+	// Score is the predicted rating on a 0-1 scale.
+	Score   float64 `json:"score"`
+	Source  string  `json:"source"`
+	Because *string `json:"because"`
+	Reason  string  `json:"reason"`
+}
+
+// load builds the index from every vote the first time it is needed. The
+// lock is held while reading so no refresh can slip in between.
+func (s *RecommendationService) load(ctx context.Context) error {
+	s.index.mu.Lock()
+	defer s.index.mu.Unlock()
+	if s.index.loaded {
+		return nil
+	}
+
+	beers, err := s.recommendationRepo.getAllBeerVotes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, b := range beers {
+		s.index.applyBeer(b)
+	}
+	s.index.loaded = true
+	return nil
+}
+
+// RefreshBeer updates the index after a beer's votes, name, publication or
+// reveal changed. Like load, it reads under the lock, so a slower refresh
+// cannot overwrite a newer one with an older snapshot.
+func (s *RecommendationService) RefreshBeer(ctx context.Context, beerId int) error {
+	s.index.mu.Lock()
+	defer s.index.mu.Unlock()
+	if !s.index.loaded {
+		return nil
+	}
+	beers, err := s.recommendationRepo.getBeerVotes(ctx, beerId)
+	if err != nil {
+		return err
+	}
+	if len(beers) == 0 {
+		s.index.removeBeer(beerId)
+	}
+	for _, b := range beers {
+		s.index.applyBeer(b)
+	}
+	return nil
+}
+
+// RefreshRoom updates the index for every beer in a room, dropping beers
+// that no longer exist. It reads under the lock, as RefreshBeer does.
+func (s *RecommendationService) RefreshRoom(ctx context.Context, roomId int) error {
+	s.index.mu.Lock()
+	defer s.index.mu.Unlock()
+	if !s.index.loaded {
+		return nil
+	}
+	beers, err := s.recommendationRepo.getBeerVotesInRoom(ctx, roomId)
+	if err != nil {
+		return err
+	}
+	current := map[int]bool{}
+	for _, b := range beers {
+		current[b.id] = true
+		s.index.applyBeer(b)
+	}
+	for id, b := range s.index.beers {
+		if b.roomId == roomId && !current[id] {
+			s.index.removeBeer(id)
+		}
+	}
+	return nil
+}
+
+// GetRecommendations suggests beers the user's room mates have rated but the
+// user has not. Beers similar to ones the user rated come first; style
+// preferences fill up the list when there is too little overlap.
+func (s *RecommendationService) GetRecommendations(ctx context.Context, userId int, limit int) ([]Recommendation, error) {
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	cohort, err := s.recommendationRepo.getCohort(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	tasted, err := s.recommendationRepo.getTastedItems(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.index.mu.RLock()
+	defer s.index.mu.RUnlock()
+
+	mine := s.index.userItems[userId]
+	candidates := []string{}
+	for key, it := range s.index.items {
+		if tasted[key] {
+			continue
+		}
+		for raterId := range it.votes {
+			if cohort[raterId] {
+				candidates = append(candidates, key)
+				break
+			}
+		}
+	}
+
+	recs := []Recommendation{}
+	recommended := map[string]bool{}
+	for _, key := range candidates {
+		if rec, ok := s.predict(key, mine); ok {
+			recs = append(recs, rec)
+			recommended[key] = true
+		}
+	}
+	sortRecommendations(recs)
+	if len(recs) >= limit {
+		return recs[:limit], nil
+	}
+
+	styleRecs := []Recommendation{}
+	styles := s.stylePreferences(mine)
+	for _, key := range candidates {
+		if recommended[key] {
+			continue
+		}
+		it := s.index.items[key]
+		if it.style == nil {
+			continue
+		}
+		pref, ok := styles[styleKey(*it.style)]
+		if !ok || pref < likedThreshold {
+			continue
+		}
+		style := *it.style
+		styleRecs = append(styleRecs, Recommendation{
+			Name:    it.name,
+			Style:   it.style,
+			Score:   numeric.Round2(pref),
+			Source:  SourceStyle,
+			Because: &style,
+			Reason:  "Because you rate " + style + " highly",
+		})
+	}
+	sortRecommendations(styleRecs)
+
+	recs = append(recs, styleRecs...)
+	if len(recs) > limit {
+		recs = recs[:limit]
+	}
+	return recs, nil
+}
+
+// predict estimates the user's rating of an item as the similarity-weighted
+// average of their ratings of similar items.
+func (s *RecommendationService) predict(key string, mine map[string]float64) (Recommendation, bool) {
+	var weighted, weights, best float64
+	var because string
+	for ratedKey, r := range mine {
+		sim, ok := s.index.similarity(key, ratedKey)
+		if !ok || sim <= 0 {
+			continue
+		}
+		weighted += sim * r
+		weights += sim
+		if r >= likedThreshold && sim*r > best {
+			best = sim * r
+			because = s.index.items[ratedKey].name
+		}
+	}
+	if weights == 0 || because == "" {
+		return Recommendation{}, false
+	}
+
+	it := s.index.items[key]
+	return Recommendation{
+		Name:    it.name,
+		Style:   it.style,
+		Score:   numeric.Round2(weighted / weights),
+		Source:  SourceSimilar,
+		Because: &because,
+		Reason:  "Because you liked " + because,
+	}, true
+}
+
+// stylePreferences averages the user's ratings per style.
+func (s *RecommendationService) stylePreferences(mine map[string]float64) map[string]float64 {
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	for key, r := range mine {
+		it := s.index.items[key]
+		if it.style == nil {
+			continue
+		}
+		k := styleKey(*it.style)
+		sums[k] += r
+		counts[k]++
+	}
+	prefs := map[string]float64{}
+	for k, sum := range sums {
+		prefs[k] = sum / float64(counts[k])
+	}
+	return prefs
+}
+
+func styleKey(style string) string {
+	return itemKey(style)
+}
+
+func sortRecommendations(recs []Recommendation) {
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Score != recs[j].Score {
+			return recs[i].Score > recs[j].Score
+		}
+		return recs[i].Name < recs[j].Name
+	})
+}
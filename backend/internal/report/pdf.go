@@ -0,0 +1,170 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+	"skafteresort.se/beers/internal/results"
+)
+
+// WritePDF renders the recap as a PDF, with the pictures loaded by
+// LoadPictures.
+func (r *Report) WritePDF(w io.Writer) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(r.RoomName, true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	// The core fonts only cover cp1252, so text is translated from UTF-8.
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	width, _ := pdf.GetPageSize()
+	content := width - 40
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+	pdf.SetY(80)
+	pdf.SetFont("Helvetica", "B", 28)
+	pdf.MultiCell(content, 12, tr(r.RoomName), "", "C", false)
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.CellFormat(content, 10, tr(r.Date), "", 1, "C", false, 0, "")
+	if len(r.Participants) > 0 {
+		pdf.Ln(10)
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(content, 8, "Participants", "", 1, "C", false, 0, "")
+		pdf.SetFont("Helvetica", "", 12)
+		for _, p := range r.Participants {
+			pdf.CellFormat(content, 7, tr(p), "", 1, "C", false, 0, "")
+		}
+	}
+
+	pdf.AddPage()
+	heading(pdf, "Final ranking")
+	columns := []struct {
+		title string
+		width float64
+	}{
+		{"#", 10}, {"Beer", 60}, {"Style", 40}, {"Votes", 15}, {"Mean", 15}, {"Median", 15}, {"Std dev", 15},
+	}
+	pdf.SetFont("Helvetica", "B", 10)
+	for _, c := range columns {
+		pdf.CellFormat(c.width, 7, c.title, "B", 0, "L", false, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 10)
+	for _, b := range r.Beers {
+		rank := fmt.Sprintf("%d", b.Rank)
+		if b.Tied {
+			rank += "="
+		}
+		cells := []string{
+			rank,
+			b.Name,
+			results.FormatCell(b.Style),
+			fmt.Sprintf("%d", b.Votes),
+			results.FormatCell(b.Mean),
+			results.FormatCell(b.Median),
+			results.FormatCell(b.StdDev),
+		}
+		for i, c := range columns {
+			pdf.CellFormat(c.width, 7, truncate(pdf, tr(cells[i]), c.width), "", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	if len(r.Awards) > 0 {
+		pdf.Ln(8)
+		heading(pdf, "Awards")
+		for _, a := range r.Awards {
+			pdf.SetFont("Helvetica", "B", 11)
+			pdf.CellFormat(50, 7, tr(a.Title), "", 0, "L", false, 0, "")
+			pdf.SetFont("Helvetica", "", 11)
+			pdf.CellFormat(70, 7, truncate(pdf, tr(a.Winner), 70), "", 0, "L", false, 0, "")
+			pdf.CellFormat(0, 7, tr(a.Detail), "", 1, "L", false, 0, "")
+		}
+	}
+
+	pdf.AddPage()
+	heading(pdf, "The lineup")
+	for _, b := range r.Beers {
+		pdf.SetFont("Helvetica", "B", 13)
+		pdf.MultiCell(content, 7, tr(fmt.Sprintf("%d. %s", b.Rank, b.Name)), "", "L", false)
+		pdf.SetFont("Helvetica", "", 10)
+		details := results.FormatCell(b.Style)
+		if b.Brewery != nil {
+			details += " - " + *b.Brewery
+		}
+		if b.Abv != nil {
+			details += " - " + results.FormatCell(*b.Abv) + "%"
+		}
+		if details != "" {
+			pdf.MultiCell(content, 6, tr(details), "", "L", false)
+		}
+		if b.picture != nil {
+			picture(pdf, b)
+		}
+
+		if len(b.Distribution) == 0 {
+			pdf.CellFormat(content, 6, "No ratings.", "", 1, "L", false, 0, "")
+		}
+		pdf.SetFillColor(224, 165, 38)
+		for _, d := range b.Distribution {
+			pdf.CellFormat(15, 6, results.FormatCell(d.Value), "", 0, "L", false, 0, "")
+			barWidth := (content - 35) * d.Percent / 100
+			if barWidth > 0 {
+				pdf.Rect(pdf.GetX(), pdf.GetY()+1.5, barWidth, 3, "F")
+			}
+			pdf.SetX(pdf.GetX() + barWidth + 2)
+			pdf.CellFormat(15, 6, fmt.Sprintf("%d", d.Count), "", 1, "L", false, 0, "")
+		}
+
+		pdf.SetFont("Helvetica", "I", 10)
+		for _, n := range b.Notes {
+			pdf.MultiCell(content, 5, tr(fmt.Sprintf("\"%s\" - %s (%s)", results.FormatCell(n.Note), authorName(n.Name), results.FormatCell(n.Rating))), "", "L", false)
+		}
+		pdf.Ln(6)
+	}
+
+	return pdf.Output(w)
+}
+
+func heading(pdf *fpdf.Fpdf, text string) {
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, text, "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+}
+
+// picture places the beer's picture below its details, at most 40mm wide
+// and 60mm tall. A picture fpdf cannot decode is left out rather than
+// failing the whole PDF.
+func picture(pdf *fpdf.Fpdf, b Beer) {
+	name := fmt.Sprintf("beer-%d", b.Id)
+	opts := fpdf.ImageOptions{ImageType: b.picture.Type}
+	info := pdf.RegisterImageOptionsReader(name, opts, bytes.NewReader(b.picture.Data))
+	if !pdf.Ok() || info == nil || info.Width() == 0 {
+		pdf.ClearError()
+		return
+	}
+	width, height := 40.0, 0.0
+	if info.Height()*40/info.Width() > 60 {
+		width, height = 0, 60
+	}
+	pdf.ImageOptions(name, pdf.GetX(), pdf.GetY()+2, width, height, true, opts, 0, "")
+	pdf.Ln(2)
+}
+
+// truncate shortens text to fit a table cell.
+func truncate(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width-2 {
+		return text
+	}
+	for len(text) > 0 && pdf.GetStringWidth(text+"...") > width-2 {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
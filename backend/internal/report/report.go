@@ -0,0 +1,232 @@
+package report
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"skafteresort.se/beers/internal/providers"
+	"skafteresort.se/beers/internal/results"
+)
+
+//go:embed templates/*.html
+var templates embed.FS
+
+var reportTemplate = template.Must(
+	template.New("report.html").
+		Funcs(template.FuncMap{
+			"number": results.FormatCell,
+			"deref":  results.FormatCell,
+			"author": authorName,
+		}).
+		ParseFS(templates, "templates/report.html"),
+)
+
+// maxNotes is how many notes are quoted for each beer.
+const maxNotes = 3
+
+// picturesTimeout bounds fetching all pictures, so the PDF is written well
+// within the server's write timeout.
+const picturesTimeout = 10 * time.Second
+
+type Bucket struct {
+	Value   float64
+	Count   int
+	Percent float64
+}
+
+type Beer struct {
+	results.ExportBeer
+	Distribution []Bucket
+	Notes        []results.Rating
+
+	// picture is only loaded for the PDF; the HTML recap links to it.
+	picture *providers.Image
+}
+
+type Award struct {
+	Title  string
+	Winner string
+	Detail string
+}
+
+type Report struct {
+	RoomName     string
+	Date         string
+	GeneratedAt  time.Time
+	Participants []string
+	Beers        []Beer
+	Awards       []Award
+}
+
+// Build turns an export into a recap. The export already applies the
+// caller's visibility rules, so the report never shows more than the
+// results endpoint would.
+func Build(export *results.Export) *Report {
+	r := Report{
+		RoomName:    export.RoomName,
+		Date:        formatDate(export.PlannedDate),
+		GeneratedAt: time.Now(),
+	}
+	for _, p := range export.Participants {
+		r.Participants = append(r.Participants, p.Name)
+	}
+	for _, b := range export.Beers {
+		r.Beers = append(r.Beers, Beer{
+			ExportBeer:   b,
+			Distribution: distribution(b.Ratings),
+			Notes:        notableNotes(b),
+		})
+	}
+	r.Awards = awards(export)
+	return &r
+}
+
+func (r *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+func distribution(ratings []results.Rating) []Bucket {
+	counts := map[float64]int{}
+	for _, r := range ratings {
+		counts[r.Rating]++
+	}
+	buckets := make([]Bucket, 0, len(counts))
+	for v, c := range counts {
+		buckets = append(buckets, Bucket{
+			Value:   v,
+			Count:   c,
+			Percent: math.Round(float64(c) / float64(len(ratings)) * 100),
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Value > buckets[j].Value
+	})
+	return buckets
+}
+
+// notableNotes picks the notes furthest from the beer's mean, since those
+// explain why someone disagreed with the room. Longer notes win ties.
+func notableNotes(b results.ExportBeer) []results.Rating {
+	notes := []results.Rating{}
+	for _, r := range b.Ratings {
+		if r.Note != nil && strings.TrimSpace(*r.Note) != "" {
+			notes = append(notes, r)
+		}
+	}
+	if b.Mean != nil {
+		mean := *b.Mean
+		sort.SliceStable(notes, func(i, j int) bool {
+			di, dj := math.Abs(notes[i].Rating-mean), math.Abs(notes[j].Rating-mean)
+			if di != dj {
+				return di > dj
+			}
+			return len(*notes[i].Note) > len(*notes[j].Note)
+		})
+	}
+	if len(notes) > maxNotes {
+		notes = notes[:maxNotes]
+	}
+	return notes
+}
+
+func awards(export *results.Export) []Award {
+	awards := []Award{}
+
+	var favourite, divisive, consensus *results.ExportBeer
+	for i := range export.Beers {
+		b := &export.Beers[i]
+		if b.Votes == 0 {
+			continue
+		}
+		if favourite == nil {
+			favourite = b
+		}
+		if b.Votes < 2 {
+			continue
+		}
+		if divisive == nil || *b.StdDev > *divisive.StdDev {
+			divisive = b
+		}
+		if consensus == nil || *b.StdDev < *consensus.StdDev {
+			consensus = b
+		}
+	}
+	if favourite != nil {
+		awards = append(awards, Award{"Crowd favourite", favourite.Name, "Mean " + results.FormatCell(*favourite.Mean)})
+	}
+	if divisive != nil && *divisive.StdDev > 0 {
+		awards = append(awards, Award{"Most divisive", divisive.Name, "Standard deviation " + results.FormatCell(*divisive.StdDev)})
+	}
+	if consensus != nil && consensus != divisive {
+		awards = append(awards, Award{"Most agreed upon", consensus.Name, "Standard deviation " + results.FormatCell(*consensus.StdDev)})
+	}
+
+	var harshest, generous *results.ParticipantSummary
+	for i := range export.Participants {
+		p := &export.Participants[i]
+		if p.Average == nil {
+			continue
+		}
+		if harshest == nil || *p.Average < *harshest.Average {
+			harshest = p
+		}
+		if generous == nil || *p.Average > *generous.Average {
+			generous = p
+		}
+	}
+	if harshest != nil && generous != nil && harshest != generous {
+		awards = append(awards,
+			Award{"Harshest critic", harshest.Name, "Average given " + results.FormatCell(*harshest.Average)},
+			Award{"Most generous", generous.Name, "Average given " + results.FormatCell(*generous.Average)},
+		)
+	}
+	return awards
+}
+
+func formatDate(s string) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Format("2 January 2006")
+	}
+	return s
+}
+
+// authorName stands in for the names hidden in anonymous rooms.
+func authorName(name string) string {
+	if name == "" {
+		return "Anonymous"
+	}
+	return name
+}
+
+// LoadPictures fetches the beers' pictures for the PDF. A picture that
+// cannot be fetched is left out, and the errors are returned for logging.
+func (r *Report) LoadPictures(ctx context.Context, images *providers.ImageProvider) error {
+	if !images.Enabled() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, picturesTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := range r.Beers {
+		b := &r.Beers[i]
+		if b.PictureUrl == nil || *b.PictureUrl == "" {
+			continue
+		}
+		picture, err := images.Fetch(ctx, *b.PictureUrl)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("beer %d: %w", b.Id, err))
+			continue
+		}
+		b.picture = picture
+	}
+	return errors.Join(errs...)
+}
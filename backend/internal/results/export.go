@@ -0,0 +1,221 @@
+package results
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"skafteresort.se/beers/internal/beers"
+)
+
+type Rating struct {
+	UserId int     `json:"userId"`
+	Name   string  `json:"name"`
+	Rating float64 `json:"rating"`
+	Note   *string `json:"note"`
+
+	// key is the real user id, also in anonymous rooms.
+	key int
+}
+
+type ExportBeer struct {
+	BeerResult
+	Ratings []Rating `json:"ratings"`
+}
+
+type Export struct {
+	RoomId       int                  `json:"roomId"`
+	RoomName     string               `json:"roomName"`
+	PlannedDate  string               `json:"plannedDate"`
+	TieBreak     []string             `json:"tieBreak"`
+	Beers        []ExportBeer         `json:"beers"`
+	Participants []ParticipantSummary `json:"participants"`
+	Anonymity    beers.Anonymity      `json:"anonymity"`
+}
+
+// GetExport returns the ranked lineup with every individual rating and note,
+// under the same visibility rules as GetResults.
+func (s *ResultsService) GetExport(ctx context.Context, roomId int, showHidden bool, showUnpublished bool) (*Export, error) {
+	res, votes, err := s.getResults(ctx, roomId, showHidden, showUnpublished)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings := map[int][]Rating{}
+	for _, v := range votes {
+		userId, name := res.Anonymity.Identity(res.names, v.userId, v.userName)
+		ratings[v.beerId] = append(ratings[v.beerId], Rating{
+			UserId: userId,
+			Name:   name,
+			Rating: v.value,
+			Note:   v.note,
+			key:    v.userId,
+		})
+	}
+	if res.Anonymity != beers.AnonymityOff {
+		// Ratings come in user order, which would give away who is who.
+		for _, r := range ratings {
+			sort.SliceStable(r, func(i, j int) bool {
+				if r[i].Name != r[j].Name {
+					return r[i].Name < r[j].Name
+				}
+				if r[i].Rating != r[j].Rating {
+					return r[i].Rating > r[j].Rating
+				}
+				return res.names.Name(r[i].key) < res.names.Name(r[j].key)
+			})
+		}
+	}
+
+	export := Export{
+		RoomId:       res.RoomId,
+		RoomName:     res.RoomName,
+		PlannedDate:  res.PlannedDate,
+		TieBreak:     res.TieBreak,
+		Beers:        make([]ExportBeer, 0, len(res.Beers)),
+		Participants: res.Participants,
+		Anonymity:    res.Anonymity,
+	}
+	for _, b := range res.Beers {
+		r := ratings[b.Id]
+		if r == nil {
+			r = []Rating{}
+		}
+		export.Beers = append(export.Beers, ExportBeer{BeerResult: b, Ratings: r})
+	}
+	return &export, nil
+}
+
+// table lays the export out as one row per beer, followed by a score and a
+// note column for every participant. Cells are strings, numbers or nil.
+// Hidden rooms have no participants, so each row just lists the beer's
+// ratings.
+func (e *Export) table() [][]any {
+	header := []any{
+		"Rank", "Beer ID", "Sample", "Name", "Style", "Brewery", "ABV", "Published",
+		"Votes", "Mean", "Median", "Std Dev", "Min", "Max",
+	}
+	if e.Anonymity == beers.AnonymityHidden {
+		return e.anonymousTable(header)
+	}
+	for _, p := range e.Participants {
+		header = append(header, p.Name+" score", p.Name+" note")
+	}
+
+	rows := [][]any{header}
+	for _, b := range e.Beers {
+		row := []any{
+			b.Rank, b.Id, b.SampleNumber, b.Name, deref(b.Style), deref(b.Brewery), deref(b.Abv), b.Published,
+			b.Votes, deref(b.Mean), deref(b.Median), deref(b.StdDev), deref(b.Min), deref(b.Max),
+		}
+		byUser := map[int]Rating{}
+		for _, r := range b.Ratings {
+			byUser[r.key] = r
+		}
+		for _, p := range e.Participants {
+			r, ok := byUser[p.key]
+			if !ok {
+				row = append(row, nil, nil)
+				continue
+			}
+			row = append(row, r.Rating, deref(r.Note))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (e *Export) anonymousTable(header []any) [][]any {
+	most := 0
+	for _, b := range e.Beers {
+		most = max(most, len(b.Ratings))
+	}
+	for i := 1; i <= most; i++ {
+		n := strconv.Itoa(i)
+		header = append(header, "Rating "+n, "Note "+n)
+	}
+
+	rows := [][]any{header}
+	for _, b := range e.Beers {
+		row := []any{
+			b.Rank, b.Id, b.SampleNumber, b.Name, deref(b.Style), deref(b.Brewery), deref(b.Abv), b.Published,
+			b.Votes, deref(b.Mean), deref(b.Median), deref(b.StdDev), deref(b.Min), deref(b.Max),
+		}
+		for _, r := range b.Ratings {
+			row = append(row, r.Rating, deref(r.Note))
+		}
+		for i := len(b.Ratings); i < most; i++ {
+			row = append(row, nil, nil)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (e *Export) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(e)
+}
+
+func (e *Export) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	for _, row := range e.table() {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = FormatCell(cell)
+			if _, ok := cell.(string); ok {
+				record[i] = escapeFormula(record[i])
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (e *Export) WriteXLSX(w io.Writer) error {
+	return writeXLSX(w, "Ratings", e.table())
+}
+
+// FormatCell writes a value the way exports and reports show it: numbers
+// without trailing zeros and missing values as blanks.
+func FormatCell(cell any) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case *string:
+		return FormatCell(deref(v))
+	case *float64:
+		return FormatCell(deref(v))
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// escapeFormula stops spreadsheet applications from evaluating notes and
+// names that start like a formula.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func deref[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -0,0 +1,158 @@
+package results
+
+import (
+	"math"
+	"sort"
+
+	"skafteresort.se/beers/internal/numeric"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+type voteKey struct {
+	userId int
+	beerId int
+}
+
+// normalizeVotes returns every vote as a z-score and as a rank score
+// within its rater's own ratings. Raters who gave every beer the same
+// rating say nothing about which they preferred, so they get neutral
+// scores.
+func normalizeVotes(votes []roomVote) (map[voteKey]float64, map[voteKey]float64) {
+	byUser := map[int][]roomVote{}
+	for _, v := range votes {
+		byUser[v.userId] = append(byUser[v.userId], v)
+	}
+
+	zScores := make(map[voteKey]float64, len(votes))
+	rankScores := make(map[voteKey]float64, len(votes))
+	for _, userVotes := range byUser {
+		values := make([]float64, len(userVotes))
+		for i, v := range userVotes {
+			values[i] = v.value
+		}
+		mean, stdDev := meanStdDev(values)
+		ranked := numeric.Ranks(values)
+
+		for i, v := range userVotes {
+			key := voteKey{userId: v.userId, beerId: v.beerId}
+			zScores[key] = 0
+			if stdDev > 0 {
+				zScores[key] = (v.value - mean) / stdDev
+			}
+			rankScores[key] = 0.5
+			if len(values) > 1 {
+				rankScores[key] = (ranked[i] - 1) / float64(len(values)-1)
+			}
+		}
+	}
+	return zScores, rankScores
+}
+
+// applyNormalizedScores sets each beer's mean z-score and rank score.
+func applyNormalizedScores(results []BeerResult, votes []roomVote) {
+	zScores, rankScores := normalizeVotes(votes)
+	type sums struct {
+		z, rank float64
+		n       int
+	}
+	byBeer := map[int]*sums{}
+	for _, v := range votes {
+		s, ok := byBeer[v.beerId]
+		if !ok {
+			s = &sums{}
+			byBeer[v.beerId] = s
+		}
+		key := voteKey{userId: v.userId, beerId: v.beerId}
+		s.z += zScores[key]
+		s.rank += rankScores[key]
+		s.n++
+	}
+	for i := range results {
+		if s, ok := byBeer[results[i].Id]; ok {
+			results[i].ZScore = numeric.Ptr(numeric.Round2(s.z / float64(s.n)))
+			results[i].RankScore = numeric.Ptr(numeric.Round2(s.rank / float64(s.n)))
+		}
+	}
+}
+
+// compareBy orders two beers by the method's score, falling back to the raw
+// tie-breaking rules. Beers without a score sort last.
+func compareBy(method rooms.RankingMethod, a *BeerResult, b *BeerResult) int {
+	var ka, kb *float64
+	switch method {
+	case rooms.RankingZScore:
+		ka, kb = a.ZScore, b.ZScore
+	case rooms.RankingRank:
+		ka, kb = a.RankScore, b.RankScore
+	}
+	switch {
+	case ka == nil && kb != nil:
+		return 1
+	case ka != nil && kb == nil:
+		return -1
+	case ka != nil && kb != nil:
+		if c := cmpFloat(*kb, *ka); c != 0 {
+			return c
+		}
+	}
+	return compare(a.Summary, b.Summary)
+}
+
+// rank assigns every beer its competition rank ("1224") under each method
+// and sorts the lineup by the official method. Beers that only differ by id
+// share a rank and are marked as tied.
+func rank(results []BeerResult, official rooms.RankingMethod) map[rooms.RankingMethod][]int {
+	orderings := map[rooms.RankingMethod][]int{}
+	for i := range results {
+		results[i].Ranks = map[rooms.RankingMethod]int{}
+	}
+
+	for _, method := range rooms.RankingMethods {
+		sort.SliceStable(results, func(i, j int) bool {
+			if c := compareBy(method, &results[i], &results[j]); c != 0 {
+				return c < 0
+			}
+			return results[i].Id < results[j].Id
+		})
+
+		ids := make([]int, len(results))
+		for i := range results {
+			ids[i] = results[i].Id
+			r := i + 1
+			if i > 0 && compareBy(method, &results[i-1], &results[i]) == 0 {
+				r = results[i-1].Ranks[method]
+			}
+			results[i].Ranks[method] = r
+		}
+		orderings[method] = ids
+	}
+
+	position := map[int]int{}
+	for i, id := range orderings[official] {
+		position[id] = i
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return position[results[i].Id] < position[results[j].Id]
+	})
+	for i := range results {
+		results[i].Rank = results[i].Ranks[official]
+		if i > 0 && results[i].Rank == results[i-1].Rank {
+			results[i].Tied = true
+			results[i-1].Tied = true
+		}
+	}
+	return orderings
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
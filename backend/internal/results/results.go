@@ -0,0 +1,135 @@
+package results
+
+import (
+	"context"
+	"database/sql"
+
+	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+type ResultsRepo struct {
+	db *sql.DB
+}
+
+type roomInfo struct {
+	name        string
+	plannedDate string
+	blind       bool
+
+	rankingMethod rooms.RankingMethod
+	anonymity     beers.Anonymity
+	names         beers.Pseudonyms
+}
+
+type lineupBeer struct {
+	id           int
+	name         string
+	style        *string
+	brewery      *string
+	abv          *float64
+	pictureUrl   *string
+	published    bool
+	revealed     bool
+	sampleNumber int
+}
+
+type roomVote struct {
+	userId   int
+	userName string
+	beerId   int
+	value    float64
+	note     *string
+}
+
+func NewResultsRepo(db *sql.DB) *ResultsRepo {
+	return &ResultsRepo{
+		db: db,
+	}
+}
+
+func (rr *ResultsRepo) getRoomInfo(ctx context.Context, roomId int) (roomInfo, error) {
+	var info roomInfo
+	var salt string
+	err := rr.db.QueryRowContext(ctx, `
+      SELECT name, planned_date, blind_mode, ranking_method, anonymity, pseudonym_salt
+      FROM rooms
+      WHERE id = ?
+    `,
+		roomId,
+	).Scan(&info.name, &info.plannedDate, &info.blind, &info.rankingMethod, &info.anonymity, &salt)
+	if err != nil || info.anonymity == beers.AnonymityOff {
+		return info, err
+	}
+	info.names, err = beers.LoadPseudonyms(ctx, rr.db, roomId, salt)
+	return info, err
+}
+
+func (rr *ResultsRepo) getLineup(ctx context.Context, roomId int) ([]lineupBeer, error) {
+	rows, err := rr.db.QueryContext(ctx, `
+      SELECT id, name, style, brewery, abv, pictureurl, published, revealed, sample_number
+      FROM beers_votes
+      WHERE room_id = ?
+      ORDER BY id ASC
+    `,
+		roomId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lineup := []lineupBeer{}
+	for rows.Next() {
+		var b lineupBeer
+		err := rows.Scan(
+			&b.id,
+			&b.name,
+			&b.style,
+			&b.brewery,
+			&b.abv,
+			&b.pictureUrl,
+			&b.published,
+			&b.revealed,
+			&b.sampleNumber,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lineup = append(lineup, b)
+	}
+	return lineup, rows.Err()
+}
+
+func (rr *ResultsRepo) getVotesInRoom(ctx context.Context, roomId int) ([]roomVote, error) {
+	rows, err := rr.db.QueryContext(ctx, `
+      SELECT
+        votes.user_id,
+        IF(users.name != '', users.name, users.username) AS userName,
+        votes.beer_id,
+        votes.points,
+        votes.note
+      FROM votes
+      JOIN beers ON beers.id = votes.beer_id
+      JOIN users ON users.id = votes.user_id
+      WHERE beers.room_id = ?
+      ORDER BY votes.user_id, votes.beer_id
+    `,
+		roomId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	votes := []roomVote{}
+	for rows.Next() {
+		var v roomVote
+		err := rows.Scan(&v.userId, &v.userName, &v.beerId, &v.value, &v.note)
+		if err != nil {
+			return nil, err
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
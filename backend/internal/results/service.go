@@ -0,0 +1,221 @@
+package results
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/numeric"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+type ResultsService struct {
+	resultsRepo *ResultsRepo
+	logger      *slog.Logger
+}
+
+func NewResultsService(rr *ResultsRepo, logger *slog.Logger) *ResultsService {
+	rs := ResultsService{
+		resultsRepo: rr,
+		logger:      logger,
+	}
+	return &rs
+}
+
+type BeerResult struct {
+	Rank         int      `json:"rank"`
+	Tied         bool     `json:"tied"`
+	Id           int      `json:"id"`
+	Name         string   `json:"name"`
+	Style        *string  `json:"style"`
+	Brewery      *string  `json:"brewery"`
+	Abv          *float64 `json:"abv"`
+	PictureUrl   *string  `json:"pictureUrl"`
+	Published    bool     `json:"published"`
+	Revealed     bool     `json:"revealed"`
+	SampleNumber int      `json:"sampleNumber"`
+	Summary
+
+	ZScore    *float64                    `json:"zScore"`
+	RankScore *float64                    `json:"rankScore"`
+	Ranks     map[rooms.RankingMethod]int `json:"ranks"`
+}
+
+type TopPick struct {
+	BeerId int     `json:"beerId"`
+	Name   string  `json:"name"`
+	Rating float64 `json:"rating"`
+}
+
+type ParticipantSummary struct {
+	UserId  int      `json:"userId"`
+	Name    string   `json:"name"`
+	Votes   int      `json:"votes"`
+	Average *float64 `json:"average"`
+	TopPick *TopPick `json:"topPick"`
+
+	// key is the real user id, also in anonymous rooms.
+	key int
+}
+
+type Results struct {
+	RoomId      int          `json:"roomId"`
+	RoomName    string       `json:"roomName"`
+	PlannedDate string       `json:"plannedDate"`
+	TieBreak    []string     `json:"tieBreak"`
+	Beers       []BeerResult `json:"beers"`
+	// RankingMethod is the room's official ordering of Beers. Orderings
+	// lists the beer ids under every method.
+	RankingMethod rooms.RankingMethod           `json:"rankingMethod"`
+	Orderings     map[rooms.RankingMethod][]int `json:"orderings"`
+	Participants  []ParticipantSummary          `json:"participants"`
+	// Anonymity hides participants behind pseudonyms, or drops the
+	// participant summaries altogether.
+	Anonymity beers.Anonymity `json:"anonymity"`
+	names     beers.Pseudonyms
+}
+
+// GetResults ranks the room's lineup and summarizes each participant.
+// Without showUnpublished only published beers are included, and participant
+// summaries only count votes on the included beers. Without showHidden,
+// unrevealed beers in blind rooms are concealed.
+func (s *ResultsService) GetResults(ctx context.Context, roomId int, showHidden bool, showUnpublished bool) (*Results, error) {
+	res, _, err := s.getResults(ctx, roomId, showHidden, showUnpublished)
+	return res, err
+}
+
+// getResults also returns the votes on the included beers, for callers that
+// need the individual ratings.
+func (s *ResultsService) getResults(ctx context.Context, roomId int, showHidden bool, showUnpublished bool) (*Results, []roomVote, error) {
+	info, err := s.resultsRepo.getRoomInfo(ctx, roomId)
+	if err != nil {
+		return nil, nil, err
+	}
+	lineup, err := s.resultsRepo.getLineup(ctx, roomId)
+	if err != nil {
+		return nil, nil, err
+	}
+	votes, err := s.resultsRepo.getVotesInRoom(ctx, roomId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	valuesByBeer := map[int][]float64{}
+	for _, v := range votes {
+		valuesByBeer[v.beerId] = append(valuesByBeer[v.beerId], v.value)
+	}
+
+	included := map[int]*BeerResult{}
+	results := []BeerResult{}
+	for _, b := range lineup {
+		if !b.published && !showUnpublished {
+			continue
+		}
+		br := BeerResult{
+			Id:           b.id,
+			Name:         b.name,
+			Style:        b.style,
+			Brewery:      b.brewery,
+			Abv:          b.abv,
+			PictureUrl:   b.pictureUrl,
+			Published:    b.published,
+			Revealed:     b.revealed,
+			SampleNumber: b.sampleNumber,
+			Summary:      summarize(valuesByBeer[b.id]),
+		}
+		if info.blind && !b.revealed && !showHidden {
+			br.Name = beers.SampleName(b.sampleNumber)
+			br.Style = nil
+			br.Brewery = nil
+			br.Abv = nil
+			br.PictureUrl = nil
+		}
+		results = append(results, br)
+	}
+
+	ids := map[int]bool{}
+	for _, b := range results {
+		ids[b.Id] = true
+	}
+	visible := []roomVote{}
+	for _, v := range votes {
+		if ids[v.beerId] {
+			visible = append(visible, v)
+		}
+	}
+
+	// Normalizing over the visible votes only keeps hidden ratings from
+	// leaking into the scores.
+	applyNormalizedScores(results, visible)
+	orderings := rank(results, info.rankingMethod)
+	for i := range results {
+		included[results[i].Id] = &results[i]
+	}
+
+	return &Results{
+		RoomId:       roomId,
+		RoomName:     info.name,
+		PlannedDate:  info.plannedDate,
+		TieBreak:     TieBreak,
+		Beers:        results,
+		Participants: anonymizeParticipants(summarizeParticipants(visible, included), info),
+
+		RankingMethod: info.rankingMethod,
+		Orderings:     orderings,
+		Anonymity:     info.anonymity,
+		names:         info.names,
+	}, visible, nil
+}
+
+// anonymizeParticipants hides who is who in anonymous rooms. Participants
+// keep their summaries under a pseudonym, and in hidden rooms there is
+// nobody left to summarize.
+func anonymizeParticipants(participants []ParticipantSummary, info roomInfo) []ParticipantSummary {
+	switch info.anonymity {
+	case beers.AnonymityHidden:
+		return []ParticipantSummary{}
+	case beers.AnonymityPseudonym:
+		for i := range participants {
+			p := &participants[i]
+			p.UserId, p.Name = info.anonymity.Identity(info.names, p.key, p.Name)
+		}
+		sort.SliceStable(participants, func(i, j int) bool {
+			return participants[i].Name < participants[j].Name
+		})
+	}
+	return participants
+}
+
+func summarizeParticipants(votes []roomVote, included map[int]*BeerResult) []ParticipantSummary {
+	summaries := []ParticipantSummary{}
+	byUser := map[int]int{}
+	sums := map[int]float64{}
+
+	for _, v := range votes {
+		beer := included[v.beerId]
+		i, ok := byUser[v.userId]
+		if !ok {
+			i = len(summaries)
+			byUser[v.userId] = i
+			summaries = append(summaries, ParticipantSummary{UserId: v.userId, Name: v.userName, key: v.userId})
+		}
+		p := &summaries[i]
+		p.Votes++
+		sums[v.userId] += v.value
+
+		// Ties between a participant's favourites go to the beer ranked
+		// higher overall.
+		if p.TopPick == nil ||
+			v.value > p.TopPick.Rating ||
+			(v.value == p.TopPick.Rating && beer.Rank < included[p.TopPick.BeerId].Rank) {
+			p.TopPick = &TopPick{BeerId: beer.Id, Name: beer.Name, Rating: v.value}
+		}
+	}
+
+	for i := range summaries {
+		p := &summaries[i]
+		p.Average = numeric.Ptr(numeric.Round2(sums[p.key] / float64(p.Votes)))
+	}
+	return summaries
+}
@@ -0,0 +1,86 @@
+package results
+
+import (
+	"sort"
+
+	"skafteresort.se/beers/internal/numeric"
+)
+
+// Summary describes the distribution of the votes on one beer. The
+// statistics are nil when nobody has voted.
+type Summary struct {
+	Votes  int      `json:"votes"`
+	Mean   *float64 `json:"mean"`
+	Median *float64 `json:"median"`
+	StdDev *float64 `json:"stdDev"`
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+}
+
+// summarize uses the population standard deviation, since the votes are
+// everyone in the room rather than a sample of them.
+func summarize(values []float64) Summary {
+	s := Summary{Votes: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mean, stdDev := meanStdDev(sorted)
+
+	mid := len(sorted) / 2
+	median := sorted[mid]
+	if len(sorted)%2 == 0 {
+		median = (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	s.Mean = numeric.Ptr(numeric.Round2(mean))
+	s.Median = numeric.Ptr(numeric.Round2(median))
+	s.StdDev = numeric.Ptr(numeric.Round2(stdDev))
+	s.Min = numeric.Ptr(sorted[0])
+	s.Max = numeric.Ptr(sorted[len(sorted)-1])
+	return s
+}
+
+// TieBreak lists the ordering applied to the lineup, in priority order.
+var TieBreak = []string{"mean desc", "median desc", "votes desc", "stdDev asc", "id asc"}
+
+// compare orders two summaries by TieBreak, leaving out the final id
+// comparison. Beers without votes sort after every beer with votes.
+func compare(a Summary, b Summary) int {
+	if a.Votes == 0 || b.Votes == 0 {
+		return cmpInt(b.Votes, a.Votes)
+	}
+	if c := cmpFloat(*b.Mean, *a.Mean); c != 0 {
+		return c
+	}
+	if c := cmpFloat(*b.Median, *a.Median); c != 0 {
+		return c
+	}
+	if c := cmpInt(b.Votes, a.Votes); c != 0 {
+		return c
+	}
+	return cmpFloat(*a.StdDev, *b.StdDev)
+}
+
+func cmpFloat(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func cmpInt(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,17 @@
+package rooms
+
+type ConfirmationError struct {
+	ErrorInfo string
+}
+
+func (e ConfirmationError) Error() string {
+	return e.ErrorInfo
+}
+
+type InvalidSettingsError struct {
+	ErrorInfo string
+}
+
+func (e InvalidSettingsError) Error() string {
+	return e.ErrorInfo
+}
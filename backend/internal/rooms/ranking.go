@@ -0,0 +1,26 @@
+package rooms
+
+// RankingMethod chooses which score orders a room's official leaderboard.
+type RankingMethod string
+
+const (
+	// RankingRaw orders beers by their plain mean rating.
+	RankingRaw RankingMethod = "raw"
+	// RankingZScore orders beers by the mean of each rater's z-score, so a
+	// 4 from someone who rates everything 4-5 counts less than a 4 from
+	// someone who rates everything 2-3.
+	RankingZScore RankingMethod = "zscore"
+	// RankingRank orders beers by where each rater placed them among their
+	// own ratings, from 0 for their lowest to 1 for their highest.
+	RankingRank RankingMethod = "rank"
+)
+
+var RankingMethods = []RankingMethod{RankingRaw, RankingZScore, RankingRank}
+
+func (m RankingMethod) Valid() bool {
+	switch m {
+	case RankingRaw, RankingZScore, RankingRank:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package rooms
+
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleHost      Role = "host"
+	RoleTaster    Role = "taster"
+	RoleSpectator Role = "spectator"
+)
+
+type Permission string
+
+const (
+	PermissionView              Permission = "view"
+	PermissionRate              Permission = "rate"
+	PermissionManageLineup      Permission = "manage-lineup"
+	PermissionPublish           Permission = "publish"
+	PermissionManageMembers     Permission = "manage-members"
+	PermissionManageRoom        Permission = "manage-room"
+	PermissionDeleteRoom        Permission = "delete-room"
+	PermissionTransferOwnership Permission = "transfer-ownership"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: {
+		PermissionView,
+		PermissionRate,
+		PermissionManageLineup,
+		PermissionPublish,
+		PermissionManageMembers,
+		PermissionManageRoom,
+		PermissionDeleteRoom,
+		PermissionTransferOwnership,
+	},
+	RoleHost: {
+		PermissionView,
+		PermissionRate,
+		PermissionManageLineup,
+		PermissionPublish,
+		PermissionManageMembers,
+		PermissionManageRoom,
+	},
+	RoleTaster: {
+		PermissionView,
+		PermissionRate,
+	},
+	RoleSpectator: {
+		PermissionView,
+	},
+}
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r Role) Can(p Permission) bool {
+	for _, rp := range rolePermissions[r] {
+		if rp == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// roleRanks orders the roles by how much they are trusted with.
+var roleRanks = map[Role]int{
+	RoleSpectator: 1,
+	RoleTaster:    2,
+	RoleHost:      3,
+	RoleOwner:     4,
+}
+
+// Outranks reports whether r ranks above other. Members only manage members
+// they outrank, and only hand out roles below their own, so hosts cannot
+// demote or remove each other.
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
@@ -3,8 +3,11 @@ package rooms
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+
+	"skafteresort.se/beers/internal/beers"
 )
 
 type RoomRepo struct {
@@ -19,28 +22,123 @@ type Room struct {
 	Description string  `db:"description" json:"description"`
 	PlannedDate string  `db:"planned_date" json:"plannedDate"`
 	Members     int     `db:"members" json:"members"`
+	State       State   `db:"state" json:"state"`
+	Archived    bool    `db:"archived" json:"archived"`
+
+	VotingDeadline *time.Time `db:"voting_deadline" json:"votingDeadline"`
+
+	Settings RoomSettings `json:"settings"`
+}
+
+type CloneOptions struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	PlannedDate  string `json:"plannedDate"`
+	CopyMembers  bool   `json:"copyMembers"`
+	CopyBeers    bool   `json:"copyBeers"`
+	CopySettings bool   `json:"copySettings"`
+}
+
+type RoomSettings struct {
+	RequireApproval bool              `db:"require_approval" json:"requireApproval"`
+	RatingScale     beers.RatingScale `json:"ratingScale"`
+	BlindMode       bool              `db:"blind_mode" json:"blindMode"`
+
+	RankingMethod RankingMethod `db:"ranking_method" json:"rankingMethod"`
+	// FreezeVotesOnPublish stops participants from changing their votes
+	// once a beer's ratings are published.
+	FreezeVotesOnPublish bool `db:"freeze_votes_on_publish" json:"freezeVotesOnPublish"`
+	// HostRatingsView decides whether hosts see every rating or only how
+	// many have voted before the ratings are published.
+	HostRatingsView beers.HostRatingsView `db:"host_ratings_view" json:"hostRatingsView"`
+	// Anonymity hides who gave which rating from everyone, hosts included.
+	Anonymity beers.Anonymity `db:"anonymity" json:"anonymity"`
+	// AutoPublish publishes a beer's ratings once everyone who can vote has
+	// voted. With AutoPublishAfter set, the first vote also starts a timeout
+	// in seconds after which the ratings are published anyway.
+	AutoPublish      bool `db:"auto_publish" json:"autoPublish"`
+	AutoPublishAfter int  `db:"auto_publish_after" json:"autoPublishAfter"`
+}
+
+func DefaultRoomSettings() RoomSettings {
+	return RoomSettings{
+		RatingScale:     beers.DefaultRatingScale,
+		RankingMethod:   RankingRaw,
+		HostRatingsView: beers.HostRatingsAll,
+		Anonymity:       beers.AnonymityOff,
+	}
+}
+
+func (s RoomSettings) Valid() error {
+	if err := s.RatingScale.Valid(); err != nil {
+		return err
+	}
+	if !s.RankingMethod.Valid() {
+		return InvalidSettingsError{ErrorInfo: "Ranking method must be raw, zscore or rank"}
+	}
+	if !s.HostRatingsView.Valid() {
+		return InvalidSettingsError{ErrorInfo: "Host ratings view must be all or counts"}
+	}
+	if !s.Anonymity.Valid() {
+		return InvalidSettingsError{ErrorInfo: "Anonymity must be off, pseudonym or hidden"}
+	}
+	if s.AutoPublishAfter < 0 {
+		return InvalidSettingsError{ErrorInfo: "Auto-publish timeout cannot be negative"}
+	}
+	return nil
+}
+
+type JoinRequest struct {
+	Id        int    `json:"id"`
+	RoomId    int    `json:"roomId"`
+	UserId    int    `json:"userId"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"createdAt"`
 }
 
 type RelatedBeer struct {
-	Id         int      `json:"id"`
-	Name       string   `json:"name"`
-	Style      *string  `json:"style"`
-	PictureUrl *string  `json:"pictureUrl"`
-	Average    *float64 `db:"average" json:"average"`
-	Published  bool     `db:"published" json:"published"`
+	Id           int      `json:"id"`
+	Name         string   `json:"name"`
+	Style        *string  `json:"style"`
+	Brewery      *string  `json:"brewery"`
+	Abv          *float64 `json:"abv"`
+	PictureUrl   *string  `json:"pictureUrl"`
+	Average      *float64 `db:"average" json:"average"`
+	Published    bool     `db:"published" json:"published"`
+	Revealed     bool     `db:"revealed" json:"revealed"`
+	SampleNumber int      `db:"sample_number" json:"sampleNumber"`
+
+	VotingClosed   bool       `db:"voting_closed" json:"votingClosed"`
+	VotingDeadline *time.Time `db:"voting_deadline" json:"votingDeadline"`
+}
+
+func (b *RelatedBeer) Conceal() {
+	b.Name = beers.SampleName(b.SampleNumber)
+	b.Style = nil
+	b.Brewery = nil
+	b.Abv = nil
+	b.PictureUrl = nil
 }
 
 type RelatedUser struct {
 	Id      int    `json:"id"`
 	Name    string `json:"name"`
+	Role    Role   `json:"role"`
 	IsAdmin bool   `json:"isAdmin"`
 }
 
+// execer is satisfied by both *sql.DB and *sql.Tx, so statements can be
+// shared between plain queries and transactions.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 func NewRoomRepo(db *sql.DB) *RoomRepo {
 	return &RoomRepo{db}
 }
 
-func (rr *RoomRepo) getRoomsByUserId(ctx context.Context, userId int) ([]Room, error) {
+func (rr *RoomRepo) getRoomsByUserId(ctx context.Context, userId int, archived bool) ([]Room, error) {
 	rows, err := rr.db.QueryContext(ctx, `
     SELECT
       rooms.id,
@@ -52,12 +150,15 @@ func (rr *RoomRepo) getRoomsByUserId(ctx context.Context, userId int) ([]Room, e
       	SELECT count(*)
       	FROM user_room
       	WHERE user_room.room_id = rooms.id
-      ) as members
+      ) as members,
+      rooms.state,
+      user_room.archived
     FROM rooms
     JOIN user_room ON user_room.room_id = rooms.id
     WHERE user_room.user_id = ?
+    AND user_room.archived = ?
     GROUP BY rooms.id
-  `, userId)
+  `, userId, archived)
 	if err != nil {
 		return []Room{}, err
 	}
@@ -72,6 +173,8 @@ func (rr *RoomRepo) getRoomsByUserId(ctx context.Context, userId int) ([]Room, e
 			&room.Description,
 			&room.PlannedDate,
 			&room.Members,
+			&room.State,
+			&room.Archived,
 		)
 		if err != nil {
 			return []Room{}, err
@@ -84,7 +187,9 @@ func (rr *RoomRepo) getRoomsByUserId(ctx context.Context, userId int) ([]Room, e
 func (rr *RoomRepo) getRoomByCode(ctx context.Context, code string) (*Room, error) {
 	row := rr.db.QueryRowContext(ctx, `
     SELECT
-      rooms.id
+      rooms.id,
+      rooms.state,
+      rooms.require_approval
     FROM rooms
     WHERE code = ?
   `,
@@ -93,6 +198,8 @@ func (rr *RoomRepo) getRoomByCode(ctx context.Context, code string) (*Room, erro
 	var room Room
 	err := row.Scan(
 		&room.Id,
+		&room.State,
+		&room.Settings.RequireApproval,
 	)
 	if err != nil {
 		return nil, err
@@ -112,7 +219,21 @@ func (rr *RoomRepo) getRoomById(ctx context.Context, roomId int) (*Room, error)
       	SELECT count(*)
       	FROM user_room
       	WHERE user_room.room_id = rooms.id
-      ) as members
+      ) as members,
+      rooms.state,
+      rooms.require_approval,
+      rooms.scale_min,
+      rooms.scale_max,
+      rooms.scale_step,
+      rooms.allow_half_points,
+      rooms.blind_mode,
+      rooms.ranking_method,
+      rooms.freeze_votes_on_publish,
+      rooms.host_ratings_view,
+      rooms.anonymity,
+      rooms.auto_publish,
+      rooms.auto_publish_after,
+      rooms.voting_deadline
     FROM rooms
     WHERE id = ?
 `, roomId)
@@ -124,6 +245,20 @@ func (rr *RoomRepo) getRoomById(ctx context.Context, roomId int) (*Room, error)
 		&room.Description,
 		&room.PlannedDate,
 		&room.Members,
+		&room.State,
+		&room.Settings.RequireApproval,
+		&room.Settings.RatingScale.Min,
+		&room.Settings.RatingScale.Max,
+		&room.Settings.RatingScale.Step,
+		&room.Settings.RatingScale.AllowHalfPoints,
+		&room.Settings.BlindMode,
+		&room.Settings.RankingMethod,
+		&room.Settings.FreezeVotesOnPublish,
+		&room.Settings.HostRatingsView,
+		&room.Settings.Anonymity,
+		&room.Settings.AutoPublish,
+		&room.Settings.AutoPublishAfter,
+		&room.VotingDeadline,
 	)
 	if err != nil {
 		return nil, err
@@ -133,7 +268,7 @@ func (rr *RoomRepo) getRoomById(ctx context.Context, roomId int) (*Room, error)
 
 func (rr *RoomRepo) getUsersInRoom(ctx context.Context, roomId int) ([]RelatedUser, error) {
 	rows, err := rr.db.QueryContext(ctx, `
-    SELECT users.id, users.username, user_room.is_admin
+    SELECT users.id, users.username, user_room.role
     FROM users
     JOIN user_room ON user_room.user_id = users.id
     WHERE user_room.room_id = ?
@@ -145,30 +280,47 @@ func (rr *RoomRepo) getUsersInRoom(ctx context.Context, roomId int) ([]RelatedUs
 	relatedUsers := []RelatedUser{}
 	for rows.Next() {
 		var u RelatedUser
-		err := rows.Scan(&u.Id, &u.Name, &u.IsAdmin)
+		err := rows.Scan(&u.Id, &u.Name, &u.Role)
 		if err != nil {
 			return []RelatedUser{}, err
 		}
+		u.IsAdmin = u.Role.Can(PermissionManageLineup)
 		relatedUsers = append(relatedUsers, u)
 	}
 	return relatedUsers, nil
 }
 
-func (rr *RoomRepo) getBeersInRoom(ctx context.Context, roomId int) ([]RelatedBeer, error) {
+func (rr *RoomRepo) getBeersInRoom(ctx context.Context, roomId int) ([]RelatedBeer, RoomSettings, error) {
+	var settings RoomSettings
+	row := rr.db.QueryRowContext(ctx, `
+    SELECT blind_mode, host_ratings_view
+    FROM rooms
+    WHERE id = ?
+  `, roomId)
+	if err := row.Scan(&settings.BlindMode, &settings.HostRatingsView); err != nil {
+		return []RelatedBeer{}, settings, err
+	}
+
 	rows, err := rr.db.QueryContext(ctx, `
     SELECT
       beers_votes.id,
       beers_votes.name,
       beers_votes.style,
+      beers_votes.brewery,
+      beers_votes.abv,
       beers_votes.pictureurl,
       beers_votes.average,
-      beers_votes.published
+      beers_votes.published,
+      beers_votes.revealed,
+      beers_votes.sample_number,
+      beers_votes.voting_closed,
+      beers_votes.voting_deadline
     FROM beers_votes
     WHERE beers_votes.room_id = ?
     ORDER BY beers_votes.id ASC
   `, roomId)
 	if err != nil {
-		return []RelatedBeer{}, err
+		return []RelatedBeer{}, settings, err
 	}
 
 	beers := []RelatedBeer{}
@@ -178,29 +330,53 @@ func (rr *RoomRepo) getBeersInRoom(ctx context.Context, roomId int) ([]RelatedBe
 			&beer.Id,
 			&beer.Name,
 			&beer.Style,
+			&beer.Brewery,
+			&beer.Abv,
 			&beer.PictureUrl,
 			&beer.Average,
 			&beer.Published,
+			&beer.Revealed,
+			&beer.SampleNumber,
+			&beer.VotingClosed,
+			&beer.VotingDeadline,
 		)
 		if err != nil {
-			return []RelatedBeer{}, err
+			return []RelatedBeer{}, settings, err
 		}
 		beers = append(beers, beer)
 	}
 
-	return beers, nil
+	return beers, settings, nil
 }
 
 func (rr *RoomRepo) createNewRoom(ctx context.Context, room Room) (int, error) {
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := insertRoom(ctx, tx, room)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func insertRoom(ctx context.Context, ex execer, room Room) (int, error) {
 	code := uuid.NewString()
-	res, err := rr.db.ExecContext(ctx, `
-    INSERT INTO rooms (name, code, planned_date, description)
-    VALUES (?, ?, ?, ?)
+	res, err := ex.ExecContext(ctx, `
+    INSERT INTO rooms (name, code, planned_date, description, pseudonym_salt)
+    VALUES (?, ?, ?, ?, ?)
   `,
 		room.Name,
 		code,
 		room.PlannedDate,
 		room.Description,
+		uuid.NewString(),
 	)
 	if err != nil {
 		return 0, err
@@ -209,17 +385,20 @@ func (rr *RoomRepo) createNewRoom(ctx context.Context, room Room) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	if err := writeRoomSettings(ctx, ex, int(id), room.Settings); err != nil {
+		return 0, err
+	}
 	return int(id), nil
 }
 
-func (rr *RoomRepo) addUserToRoom(ctx context.Context, userId int, roomId int, admin bool) error {
+func (rr *RoomRepo) addUserToRoom(ctx context.Context, userId int, roomId int, role Role) error {
 	_, err := rr.db.ExecContext(ctx, `
-    INSERT INTO user_room (room_id, user_id, is_admin)
+    INSERT INTO user_room (room_id, user_id, role)
     VALUES (?, ?, ?)
     `,
 		roomId,
 		userId,
-		admin,
+		role,
 	)
 	return err
 }
@@ -236,18 +415,52 @@ func (rr *RoomRepo) removeUserFromRoom(ctx context.Context, userId int, roomId i
 	return err
 }
 
-func (rr *RoomRepo) updateIsAdmin(ctx context.Context, roomId int, userId int, admin bool) error {
+func (rr *RoomRepo) updateRole(ctx context.Context, roomId int, userId int, role Role) error {
 	_, err := rr.db.ExecContext(ctx, `
-      UPDATE user_room SET is_admin = ?
+      UPDATE user_room SET role = ?
       WHERE user_id = ? AND room_id = ?
     `,
-		admin,
+		role,
 		userId,
 		roomId,
 	)
 	return err
 }
 
+func (rr *RoomRepo) transferOwnership(ctx context.Context, roomId int, ownerId int, newOwnerId int) error {
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+      UPDATE user_room SET role = ?
+      WHERE user_id = ? AND room_id = ?
+    `,
+		RoleHost,
+		ownerId,
+		roomId,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+      UPDATE user_room SET role = ?
+      WHERE user_id = ? AND room_id = ?
+    `,
+		RoleOwner,
+		newOwnerId,
+		roomId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (rr *RoomRepo) checkIfUserInRoom(ctx context.Context, roomId int, userId int) (bool, error) {
 	row := rr.db.QueryRowContext(ctx, `
     SELECT EXISTS (
@@ -265,9 +478,9 @@ func (rr *RoomRepo) checkIfUserInRoom(ctx context.Context, roomId int, userId in
 	return exists, err
 }
 
-func (rr *RoomRepo) checkIfUserIsAdminInRoom(ctx context.Context, roomId int, userId int) (bool, error) {
+func (rr *RoomRepo) getRoleInRoom(ctx context.Context, roomId int, userId int) (Role, error) {
 	row := rr.db.QueryRowContext(ctx, `
-      SELECT is_admin
+      SELECT role
       FROM user_room
       WHERE room_id = ?
       AND user_id = ?
@@ -275,25 +488,22 @@ func (rr *RoomRepo) checkIfUserIsAdminInRoom(ctx context.Context, roomId int, us
 		roomId,
 		userId,
 	)
-	var exists bool
-	err := row.Scan(&exists)
-	return exists, err
+	var role Role
+	err := row.Scan(&role)
+	return role, err
 }
 
-func (rr *RoomRepo) checkIfOtherAdminInRoom(ctx context.Context, roomId int, userId int) (bool, error) {
+func (rr *RoomRepo) getRoomIdForBeer(ctx context.Context, beerId int) (int, error) {
 	row := rr.db.QueryRowContext(ctx, `
-      SELECT count(*)
-      FROM user_room
-      WHERE room_id = ?
-      AND user_id != ?
-      AND is_admin = 1
+    SELECT room_id
+    FROM beers
+    WHERE id = ?
     `,
-		roomId,
-		userId,
+		beerId,
 	)
-	var admins int
-	err := row.Scan(&admins)
-	return admins > 0, err
+	var roomId int
+	err := row.Scan(&roomId)
+	return roomId, err
 }
 
 func (rr *RoomRepo) checkIfBeerInRoom(ctx context.Context, roomId int, beerId int) (bool, error) {
@@ -326,3 +536,410 @@ func (rr *RoomRepo) updateRoom(ctx context.Context, room Room) error {
 	)
 	return err
 }
+
+func (rr *RoomRepo) updateRoomSettings(ctx context.Context, roomId int, settings RoomSettings) error {
+	return writeRoomSettings(ctx, rr.db, roomId, settings)
+}
+
+func writeRoomSettings(ctx context.Context, ex execer, roomId int, settings RoomSettings) error {
+	_, err := ex.ExecContext(ctx, `
+    UPDATE rooms
+    SET
+      require_approval = ?,
+      scale_min = ?,
+      scale_max = ?,
+      scale_step = ?,
+      allow_half_points = ?,
+      blind_mode = ?,
+      ranking_method = ?,
+      freeze_votes_on_publish = ?,
+      host_ratings_view = ?,
+      anonymity = ?,
+      auto_publish = ?,
+      auto_publish_after = ?
+    WHERE id = ?
+    `,
+		settings.RequireApproval,
+		settings.RatingScale.Min,
+		settings.RatingScale.Max,
+		settings.RatingScale.Step,
+		settings.RatingScale.AllowHalfPoints,
+		settings.BlindMode,
+		settings.RankingMethod,
+		settings.FreezeVotesOnPublish,
+		settings.HostRatingsView,
+		settings.Anonymity,
+		settings.AutoPublish,
+		settings.AutoPublishAfter,
+		roomId,
+	)
+	return err
+}
+
+func (rr *RoomRepo) createJoinRequest(ctx context.Context, roomId int, userId int) (int, error) {
+	res, err := rr.db.ExecContext(ctx, `
+    INSERT INTO join_requests (room_id, user_id)
+    VALUES (?, ?)
+    `,
+		roomId,
+		userId,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func (rr *RoomRepo) checkIfPendingJoinRequest(ctx context.Context, roomId int, userId int) (bool, error) {
+	row := rr.db.QueryRowContext(ctx, `
+    SELECT EXISTS (
+      SELECT id
+      FROM join_requests
+      WHERE room_id = ?
+      AND user_id = ?
+      AND status = 'pending'
+    )
+    `,
+		roomId,
+		userId,
+	)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+func (rr *RoomRepo) getPendingJoinRequests(ctx context.Context, roomId int) ([]JoinRequest, error) {
+	rows, err := rr.db.QueryContext(ctx, `
+    SELECT
+      join_requests.id,
+      join_requests.room_id,
+      join_requests.user_id,
+      IF(users.name != '', users.name, users.username) as userName,
+      join_requests.status,
+      join_requests.created_at
+    FROM join_requests
+    JOIN users ON users.id = join_requests.user_id
+    WHERE join_requests.room_id = ?
+    AND join_requests.status = 'pending'
+    ORDER BY join_requests.created_at ASC
+  `, roomId)
+	if err != nil {
+		return []JoinRequest{}, err
+	}
+
+	requests := []JoinRequest{}
+	for rows.Next() {
+		var jr JoinRequest
+		err := rows.Scan(
+			&jr.Id,
+			&jr.RoomId,
+			&jr.UserId,
+			&jr.Name,
+			&jr.Status,
+			&jr.CreatedAt,
+		)
+		if err != nil {
+			return []JoinRequest{}, err
+		}
+		requests = append(requests, jr)
+	}
+	return requests, nil
+}
+
+func (rr *RoomRepo) getPendingJoinRequestById(ctx context.Context, roomId int, requestId int) (*JoinRequest, error) {
+	row := rr.db.QueryRowContext(ctx, `
+    SELECT id, room_id, user_id, status, created_at
+    FROM join_requests
+    WHERE id = ?
+    AND room_id = ?
+    AND status = 'pending'
+    `,
+		requestId,
+		roomId,
+	)
+	var jr JoinRequest
+	err := row.Scan(
+		&jr.Id,
+		&jr.RoomId,
+		&jr.UserId,
+		&jr.Status,
+		&jr.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &jr, nil
+}
+
+func (rr *RoomRepo) approveJoinRequest(ctx context.Context, jr JoinRequest, resolvedBy int) error {
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only a pending request can be approved, so a request that was
+	// resolved in the meantime does not add the user a second time.
+	res, err := tx.ExecContext(ctx, `
+      UPDATE join_requests
+      SET status = 'approved', resolved_at = NOW(), resolved_by = ?
+      WHERE id = ? AND status = 'pending'
+    `,
+		resolvedBy,
+		jr.Id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.ExecContext(ctx, `
+      INSERT INTO user_room (room_id, user_id, role)
+      VALUES (?, ?, ?)
+    `,
+		jr.RoomId,
+		jr.UserId,
+		RoleTaster,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (rr *RoomRepo) rejectJoinRequest(ctx context.Context, jr JoinRequest, resolvedBy int) error {
+	res, err := rr.db.ExecContext(ctx, `
+      UPDATE join_requests
+      SET status = 'rejected', resolved_at = NOW(), resolved_by = ?
+      WHERE id = ? AND status = 'pending'
+    `,
+		resolvedBy,
+		jr.Id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (rr *RoomRepo) getRoomState(ctx context.Context, roomId int) (State, error) {
+	row := rr.db.QueryRowContext(ctx, `
+    SELECT state
+    FROM rooms
+    WHERE id = ?
+    `,
+		roomId,
+	)
+	var state State
+	err := row.Scan(&state)
+	return state, err
+}
+
+func (rr *RoomRepo) updateRoomState(ctx context.Context, roomId int, state State) error {
+	_, err := rr.db.ExecContext(ctx, `
+    UPDATE rooms
+    SET state = ?
+    WHERE id = ?
+    `,
+		state,
+		roomId,
+	)
+	return err
+}
+
+func (rr *RoomRepo) updateVotingDeadline(ctx context.Context, roomId int, deadline *time.Time) error {
+	_, err := rr.db.ExecContext(ctx, `
+    UPDATE rooms
+    SET voting_deadline = ?
+    WHERE id = ?
+    `,
+		deadline,
+		roomId,
+	)
+	return err
+}
+
+func (rr *RoomRepo) updateArchived(ctx context.Context, roomId int, userId int, archived bool) error {
+	_, err := rr.db.ExecContext(ctx, `
+    UPDATE user_room
+    SET archived = ?
+    WHERE room_id = ?
+    AND user_id = ?
+    `,
+		archived,
+		roomId,
+		userId,
+	)
+	return err
+}
+
+func (rr *RoomRepo) setDeleteToken(ctx context.Context, roomId int, token string, expiresAt time.Time) error {
+	_, err := rr.db.ExecContext(ctx, `
+    UPDATE rooms
+    SET delete_token = ?, delete_token_expires_at = ?
+    WHERE id = ?
+    `,
+		token,
+		expiresAt,
+		roomId,
+	)
+	return err
+}
+
+func (rr *RoomRepo) getDeleteToken(ctx context.Context, roomId int) (*string, *time.Time, error) {
+	row := rr.db.QueryRowContext(ctx, `
+    SELECT delete_token, delete_token_expires_at
+    FROM rooms
+    WHERE id = ?
+    `,
+		roomId,
+	)
+	var token *string
+	var expiresAt *time.Time
+	err := row.Scan(&token, &expiresAt)
+	return token, expiresAt, err
+}
+
+func (rr *RoomRepo) deleteRoom(ctx context.Context, roomId int) error {
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DELETE votes FROM votes JOIN beers ON beers.id = votes.beer_id WHERE beers.room_id = ?`,
+		`DELETE FROM beers WHERE room_id = ?`,
+		`DELETE FROM join_requests WHERE room_id = ?`,
+		`DELETE FROM user_room WHERE room_id = ?`,
+		`DELETE FROM rooms WHERE id = ?`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt, roomId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// cloneRoom creates a new room from source in a single transaction. The user
+// cloning the room becomes its owner, while copied members keep their roles,
+// except that the previous owner is demoted to host.
+func (rr *RoomRepo) cloneRoom(ctx context.Context, source Room, userId int, opts CloneOptions) (int, error) {
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	room := Room{
+		Name:        opts.Name,
+		Description: opts.Description,
+		PlannedDate: opts.PlannedDate,
+		Settings:    DefaultRoomSettings(),
+	}
+	if opts.CopySettings {
+		room.Settings = source.Settings
+	}
+
+	id, err := insertRoom(ctx, tx, room)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+    INSERT INTO user_room (room_id, user_id, role)
+    VALUES (?, ?, ?)
+    `,
+		id,
+		userId,
+		RoleOwner,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.CopySettings {
+		_, err = tx.ExecContext(ctx, `
+      INSERT INTO room_criteria
+        (room_id, name, position, scale_min, scale_max, scale_step, allow_half_points, weight)
+      SELECT ?, name, position, scale_min, scale_max, scale_step, allow_half_points, weight
+      FROM room_criteria
+      WHERE room_id = ?
+      `,
+			id,
+			source.Id,
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+      INSERT INTO room_guess_rules (room_id, field, mode, points, tolerance)
+      SELECT ?, field, mode, points, tolerance
+      FROM room_guess_rules
+      WHERE room_id = ?
+      `,
+			id,
+			source.Id,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if opts.CopyMembers {
+		_, err = tx.ExecContext(ctx, `
+      INSERT INTO user_room (room_id, user_id, role)
+      SELECT ?, user_id, IF(role = ?, ?, role)
+      FROM user_room
+      WHERE room_id = ?
+      AND user_id != ?
+      `,
+			id,
+			RoleOwner,
+			RoleHost,
+			source.Id,
+			userId,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if opts.CopyBeers {
+		_, err = tx.ExecContext(ctx, `
+      INSERT INTO beers (name, style, brewery, abv, pictureurl, room_id)
+      SELECT name, style, brewery, abv, pictureurl, ?
+      FROM beers
+      WHERE room_id = ?
+      ORDER BY id ASC
+      `,
+			id,
+			source.Id,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
@@ -2,11 +2,21 @@ package rooms
 
 import (
 	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
 	"log/slog"
+	"strconv"
+	"time"
 
+	"github.com/google/uuid"
+
+	"skafteresort.se/beers/internal/beers"
 	"skafteresort.se/beers/internal/providers"
 )
 
+const deleteTokenLifetime = 10 * time.Minute
+
 type RoomService struct {
 	roomRepo   *RoomRepo
 	logger     *slog.Logger
@@ -26,8 +36,23 @@ func (s *RoomService) GetUsersInRoom(ctx context.Context, roomId int) ([]Related
 	return s.roomRepo.getUsersInRoom(ctx, roomId)
 }
 
-func (s *RoomService) GetBeersInRoom(ctx context.Context, roomId int) ([]RelatedBeer, error) {
-	return s.roomRepo.getBeersInRoom(ctx, roomId)
+// GetBeersInRoom lists the room's beers. In blind rooms, beers that have not
+// been revealed are concealed unless showHidden is set.
+func (s *RoomService) GetBeersInRoom(ctx context.Context, roomId int, showHidden bool) ([]RelatedBeer, error) {
+	related, settings, err := s.roomRepo.getBeersInRoom(ctx, roomId)
+	if err != nil {
+		return related, err
+	}
+	for i := range related {
+		if settings.BlindMode && !showHidden && !related[i].Revealed {
+			related[i].Conceal()
+		}
+		// The average gives away the ratings just as much as the votes do.
+		if !beers.RatingsVisible(related[i].Published, showHidden, settings.HostRatingsView) {
+			related[i].Average = nil
+		}
+	}
+	return related, nil
 }
 
 func (s *RoomService) GetRoomById(ctx context.Context, roomId int) (*Room, error) {
@@ -38,20 +63,41 @@ func (s *RoomService) GetRoomByCode(ctx context.Context, code string) (*Room, er
 	return s.roomRepo.getRoomByCode(ctx, code)
 }
 
-func (s *RoomService) GetRoomsByUserId(ctx context.Context, userId int) ([]Room, error) {
-	return s.roomRepo.getRoomsByUserId(ctx, userId)
+func (s *RoomService) GetRoomsByUserId(ctx context.Context, userId int, archived bool) ([]Room, error) {
+	return s.roomRepo.getRoomsByUserId(ctx, userId, archived)
 }
 
-func (s *RoomService) AddUserToRoom(ctx context.Context, roomId int, userId int, admin bool) error {
-	return s.roomRepo.addUserToRoom(ctx, userId, roomId, admin)
+func (s *RoomService) AddUserToRoom(ctx context.Context, roomId int, userId int, role Role) error {
+	return s.roomRepo.addUserToRoom(ctx, userId, roomId, role)
 }
 
 func (s *RoomService) RemoveUserFromRoom(ctx context.Context, roomId int, userId int) error {
 	return s.roomRepo.removeUserFromRoom(ctx, userId, roomId)
 }
 
-func (s *RoomService) UpdateIsAdmin(ctx context.Context, roomId int, targetUserId int, isAdmin bool) error {
-	return s.roomRepo.updateIsAdmin(ctx, roomId, targetUserId, isAdmin)
+func (s *RoomService) UpdateRole(ctx context.Context, roomId int, targetUserId int, role Role) error {
+	if err := s.roomRepo.updateRole(ctx, roomId, targetUserId, role); err != nil {
+		return err
+	}
+
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "role-updated", map[string]string{
+		"userId": strconv.Itoa(targetUserId),
+		"role":   string(role),
+	})
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
+
+func (s *RoomService) TransferOwnership(ctx context.Context, roomId int, ownerId int, newOwnerId int) error {
+	if err := s.roomRepo.transferOwnership(ctx, roomId, ownerId, newOwnerId); err != nil {
+		return err
+	}
+
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "ownership-transferred", map[string]string{
+		"userId": strconv.Itoa(newOwnerId),
+	})
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
 }
 
 func (s *RoomService) CreateNewRoom(ctx context.Context, userId int, room Room) (int, error) {
@@ -59,7 +105,7 @@ func (s *RoomService) CreateNewRoom(ctx context.Context, userId int, room Room)
 	if err != nil {
 		return id, err
 	}
-	err = s.roomRepo.addUserToRoom(ctx, userId, id, true)
+	err = s.roomRepo.addUserToRoom(ctx, userId, id, RoleOwner)
 	return id, err
 }
 
@@ -67,18 +113,209 @@ func (s *RoomService) CheckIfUserInRoom(ctx context.Context, roomId int, userId
 	return s.roomRepo.checkIfUserInRoom(ctx, roomId, userId)
 }
 
-func (s *RoomService) CheckIfUserIsAdminInRoom(ctx context.Context, roomId int, userId int) (bool, error) {
-	return s.roomRepo.checkIfUserIsAdminInRoom(ctx, roomId, userId)
+func (s *RoomService) GetRoleInRoom(ctx context.Context, roomId int, userId int) (Role, error) {
+	return s.roomRepo.getRoleInRoom(ctx, roomId, userId)
 }
 
-func (s *RoomService) CheckIfOtherAdminInRoom(ctx context.Context, roomId int, userId int) (bool, error) {
-	return s.roomRepo.checkIfOtherAdminInRoom(ctx, roomId, userId)
+// CheckPermission reports whether the user's role in the room grants the
+// permission. Users that are not members of the room have no permissions.
+func (s *RoomService) CheckPermission(ctx context.Context, roomId int, userId int, p Permission) (bool, error) {
+	role, err := s.roomRepo.getRoleInRoom(ctx, roomId, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return role.Can(p), nil
 }
 
 func (s *RoomService) CheckIfBeerInRoom(ctx context.Context, roomId int, beerId int) (bool, error) {
 	return s.roomRepo.checkIfBeerInRoom(ctx, roomId, beerId)
 }
 
+// CanSeeUnpublishedRatings reports whether the user may see ratings that
+// are not published yet: hosts can, unless the room only shows them counts.
+func (s *RoomService) CanSeeUnpublishedRatings(ctx context.Context, roomId int, userId int) (bool, error) {
+	ok, err := s.CheckPermission(ctx, roomId, userId, PermissionManageLineup)
+	if !ok || err != nil {
+		return false, err
+	}
+	room, err := s.roomRepo.getRoomById(ctx, roomId)
+	if err != nil {
+		return false, err
+	}
+	return room.Settings.HostRatingsView == beers.HostRatingsAll, nil
+}
+
+func (s *RoomService) GetRoomIdForBeer(ctx context.Context, beerId int) (int, error) {
+	return s.roomRepo.getRoomIdForBeer(ctx, beerId)
+}
+
 func (s *RoomService) UpdateRoom(ctx context.Context, room Room) error {
 	return s.roomRepo.updateRoom(ctx, room)
 }
+
+func (s *RoomService) UpdateRoomSettings(ctx context.Context, roomId int, settings RoomSettings) error {
+	return s.roomRepo.updateRoomSettings(ctx, roomId, settings)
+}
+
+func (s *RoomService) CheckIfPendingJoinRequest(ctx context.Context, roomId int, userId int) (bool, error) {
+	return s.roomRepo.checkIfPendingJoinRequest(ctx, roomId, userId)
+}
+
+func (s *RoomService) GetPendingJoinRequests(ctx context.Context, roomId int) ([]JoinRequest, error) {
+	return s.roomRepo.getPendingJoinRequests(ctx, roomId)
+}
+
+func (s *RoomService) CreateJoinRequest(ctx context.Context, roomId int, userId int) (*JoinRequest, error) {
+	id, err := s.roomRepo.createJoinRequest(ctx, roomId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "join-requested", map[string]string{
+		"requestId": strconv.Itoa(id),
+		"userId":    strconv.Itoa(userId),
+	})
+	s.centrifugo.HandleMessage(ctx, cMessage)
+
+	return &JoinRequest{
+		Id:     id,
+		RoomId: roomId,
+		UserId: userId,
+		Status: "pending",
+	}, nil
+}
+
+func (s *RoomService) ApproveJoinRequest(ctx context.Context, roomId int, requestId int, adminId int) error {
+	jr, err := s.roomRepo.getPendingJoinRequestById(ctx, roomId, requestId)
+	if err != nil {
+		return err
+	}
+	if err := s.roomRepo.approveJoinRequest(ctx, *jr, adminId); err != nil {
+		return err
+	}
+
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "join-request-approved", map[string]string{
+		"requestId": strconv.Itoa(jr.Id),
+		"userId":    strconv.Itoa(jr.UserId),
+	})
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
+
+func (s *RoomService) RejectJoinRequest(ctx context.Context, roomId int, requestId int, adminId int) error {
+	jr, err := s.roomRepo.getPendingJoinRequestById(ctx, roomId, requestId)
+	if err != nil {
+		return err
+	}
+	if err := s.roomRepo.rejectJoinRequest(ctx, *jr, adminId); err != nil {
+		return err
+	}
+
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "join-request-rejected", map[string]string{
+		"requestId": strconv.Itoa(jr.Id),
+		"userId":    strconv.Itoa(jr.UserId),
+	})
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
+
+// CheckRoomAllows reports whether the room's current state lets the user
+// perform the action. The current state is returned so callers can explain a
+// refusal.
+func (s *RoomService) CheckRoomAllows(ctx context.Context, roomId int, userId int, action Action) (State, bool, error) {
+	state, err := s.roomRepo.getRoomState(ctx, roomId)
+	if err != nil {
+		return state, false, err
+	}
+	role, err := s.roomRepo.getRoleInRoom(ctx, roomId, userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return state, false, err
+	}
+	return state, state.Allows(action, role), nil
+}
+
+func (s *RoomService) UpdateRoomState(ctx context.Context, roomId int, previous State, state State) error {
+	if err := s.roomRepo.updateRoomState(ctx, roomId, state); err != nil {
+		return err
+	}
+
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "state-changed", map[string]string{
+		"state":         string(state),
+		"previousState": string(previous),
+	})
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
+
+// UpdateVotingDeadline sets or, with a nil deadline, clears when voting
+// closes for every beer in the room.
+func (s *RoomService) UpdateVotingDeadline(ctx context.Context, roomId int, deadline *time.Time) error {
+	if err := s.roomRepo.updateVotingDeadline(ctx, roomId, deadline); err != nil {
+		return err
+	}
+
+	payload := map[string]string{"deadline": ""}
+	if deadline != nil {
+		payload["deadline"] = deadline.UTC().Format(time.RFC3339)
+	}
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "voting-deadline-updated", payload)
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
+
+func (s *RoomService) UpdateArchived(ctx context.Context, roomId int, userId int, archived bool) error {
+	return s.roomRepo.updateArchived(ctx, roomId, userId, archived)
+}
+
+// CreateDeleteToken issues a short-lived token that has to be passed to
+// DeleteRoom, so that a room is never deleted by a single stray request.
+func (s *RoomService) CreateDeleteToken(ctx context.Context, roomId int) (string, time.Time, error) {
+	token := uuid.NewString()
+	expiresAt := time.Now().Add(deleteTokenLifetime)
+	err := s.roomRepo.setDeleteToken(ctx, roomId, token, expiresAt)
+	return token, expiresAt, err
+}
+
+func (s *RoomService) DeleteRoom(ctx context.Context, roomId int, token string) error {
+	expected, expiresAt, err := s.roomRepo.getDeleteToken(ctx, roomId)
+	if err != nil {
+		return err
+	}
+	if expected == nil || expiresAt == nil || token == "" ||
+		subtle.ConstantTimeCompare([]byte(*expected), []byte(token)) != 1 {
+		return ConfirmationError{ErrorInfo: "Invalid confirmation token"}
+	}
+	if time.Now().After(*expiresAt) {
+		return ConfirmationError{ErrorInfo: "Confirmation token expired"}
+	}
+
+	if err := s.roomRepo.deleteRoom(ctx, roomId); err != nil {
+		return err
+	}
+
+	cMessage := s.centrifugo.CreateRoomMessage(roomId, "room-deleted", nil)
+	s.centrifugo.HandleMessage(ctx, cMessage)
+	return nil
+}
+
+func (s *RoomService) CloneRoom(ctx context.Context, roomId int, userId int, opts CloneOptions) (*Room, error) {
+	source, err := s.roomRepo.getRoomById(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Name == "" {
+		opts.Name = source.Name
+	}
+	if opts.Description == "" {
+		opts.Description = source.Description
+	}
+
+	id, err := s.roomRepo.cloneRoom(ctx, *source, userId, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.roomRepo.getRoomById(ctx, id)
+}
@@ -0,0 +1,59 @@
+package rooms
+
+type State string
+
+const (
+	StateDraft    State = "draft"
+	StateOpen     State = "open"
+	StateLive     State = "live"
+	StateFinished State = "finished"
+	StateArchived State = "archived"
+)
+
+type Action string
+
+const (
+	ActionJoin       Action = "join"
+	ActionVote       Action = "vote"
+	ActionEditLineup Action = "edit-lineup"
+	ActionPublish    Action = "publish"
+	ActionEditRoom   Action = "edit-room"
+)
+
+var stateTransitions = map[State][]State{
+	StateDraft:    {StateOpen},
+	StateOpen:     {StateDraft, StateLive, StateFinished},
+	StateLive:     {StateOpen, StateFinished},
+	StateFinished: {StateLive, StateArchived},
+	StateArchived: {StateFinished},
+}
+
+func (s State) Valid() bool {
+	_, ok := stateTransitions[s]
+	return ok
+}
+
+func (s State) CanTransitionTo(next State) bool {
+	for _, t := range stateTransitions[s] {
+		if t == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether a user with the given role may perform the action
+// while the room is in this state. Finished rooms are read-only for everyone
+// but the owner, and archived rooms are read-only for everyone.
+func (s State) Allows(action Action, role Role) bool {
+	switch s {
+	case StateArchived:
+		return false
+	case StateFinished:
+		return role == RoleOwner && action != ActionJoin
+	case StateDraft:
+		return action != ActionVote
+	default:
+		return true
+	}
+}
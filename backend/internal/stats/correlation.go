@@ -0,0 +1,51 @@
+package stats
+
+import (
+	"math"
+
+	"skafteresort.se/beers/internal/numeric"
+)
+
+const (
+	MethodPearson  = "pearson"
+	MethodSpearman = "spearman"
+)
+
+func ValidMethod(method string) bool {
+	return method == MethodPearson || method == MethodSpearman
+}
+
+// correlate returns the correlation between paired ratings, or nil when it
+// is undefined because one side rated everything the same.
+func correlate(method string, xs []float64, ys []float64) *float64 {
+	if method == MethodSpearman {
+		xs, ys = numeric.Ranks(xs), numeric.Ranks(ys)
+	}
+	return pearson(xs, ys)
+}
+
+func pearson(xs []float64, ys []float64) *float64 {
+	n := float64(len(xs))
+	if n < 2 {
+		return nil
+	}
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return nil
+	}
+	r := numeric.Round2(cov / math.Sqrt(varX*varY))
+	return &r
+}
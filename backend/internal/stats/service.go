@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"skafteresort.se/beers/internal/numeric"
+)
+
+const (
+	// favoriteStyles is how many of the best rated styles are highlighted.
+	favoriteStyles = 3
+	// profileStyles caps the taste profile at a readable number of axes.
+	profileStyles = 8
+	// balancedThreshold is how far, as a share of the rating scale, the
+	// user may deviate from the room before being called harsh or generous.
+	balancedThreshold = 0.05
+)
+
+type StatsService struct {
+	statsRepo *StatsRepo
+	logger    *slog.Logger
+}
+
+func NewStatsService(sr *StatsRepo, logger *slog.Logger) *StatsService {
+	ss := StatsService{
+		statsRepo: sr,
+		logger:    logger,
+	}
+	return &ss
+}
+
+type Tendency struct {
+	// Deviation is how far above (positive) or below (negative) the rest of
+	// the room the user rates, as a share of the rating scale.
+	Deviation *float64 `json:"deviation"`
+	Ratings   int      `json:"ratings"`
+	Label     string   `json:"label"`
+}
+
+type UserStats struct {
+	Tastings          int          `json:"tastings"`
+	Ratings           int          `json:"ratings"`
+	AverageGiven      *float64     `json:"averageGiven"`
+	AverageNormalized *float64     `json:"averageNormalized"`
+	FavoriteStyles    []StyleStats `json:"favoriteStyles"`
+	Tendency          Tendency     `json:"tendency"`
+	Profile           []StyleStats `json:"profile"`
+}
+
+func (s *StatsService) GetUserStats(ctx context.Context, userId int) (*UserStats, error) {
+	t, err := s.statsRepo.getTotals(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.statsRepo.getDeviation(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	styles, err := s.statsRepo.getStyleStats(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range styles {
+		styles[i].Average = numeric.Round2(styles[i].Average)
+		styles[i].Score = numeric.Round2(styles[i].Score)
+	}
+
+	stats := UserStats{
+		Tastings:          t.tastings,
+		Ratings:           t.ratings,
+		AverageGiven:      roundPtr(t.average),
+		AverageNormalized: roundPtr(t.averageNormalized),
+		FavoriteStyles:    styles[:min(favoriteStyles, len(styles))],
+		Tendency: Tendency{
+			Deviation: roundPtr(d.average),
+			Ratings:   d.ratings,
+			Label:     tendencyLabel(d.average),
+		},
+	}
+
+	// The profile shows the most tasted styles, in a stable order so the
+	// radar chart keeps its shape as ratings are added.
+	profile := append([]StyleStats(nil), styles...)
+	sort.SliceStable(profile, func(i, j int) bool {
+		return profile[i].Ratings > profile[j].Ratings
+	})
+	profile = profile[:min(profileStyles, len(profile))]
+	sort.Slice(profile, func(i, j int) bool {
+		return profile[i].Style < profile[j].Style
+	})
+	stats.Profile = profile
+
+	return &stats, nil
+}
+
+func tendencyLabel(deviation *float64) string {
+	switch {
+	case deviation == nil:
+		return "unknown"
+	case *deviation <= -balancedThreshold:
+		return "harsh"
+	case *deviation >= balancedThreshold:
+		return "generous"
+	}
+	return "balanced"
+}
+
+func roundPtr(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	r := numeric.Round2(*v)
+	return &r
+}
@@ -0,0 +1,285 @@
+package stats
+
+import (
+	"context"
+	"sort"
+
+	"skafteresort.se/beers/internal/beers"
+)
+
+const (
+	DefaultMinOverlap = 3
+	// MinOverlap is the lowest accepted threshold, since a correlation
+	// needs at least two shared beers.
+	MinOverlap = 2
+)
+
+type Taster struct {
+	UserId int    `json:"userId"`
+	Name   string `json:"name"`
+}
+
+type PalateTwin struct {
+	Taster
+	Correlation *float64 `json:"correlation"`
+	Overlap     int      `json:"overlap"`
+	SharedRooms int      `json:"sharedRooms"`
+}
+
+type PalateTwins struct {
+	Method     string       `json:"method"`
+	MinOverlap int          `json:"minOverlap"`
+	Twins      []PalateTwin `json:"twins"`
+}
+
+type SimilarityMatrix struct {
+	Method     string   `json:"method"`
+	MinOverlap int      `json:"minOverlap"`
+	Tasters    []Taster `json:"tasters"`
+	// Matrix[i][j] is the correlation between Tasters[i] and Tasters[j],
+	// or nil when they share too few beers.
+	Matrix  [][]*float64 `json:"matrix"`
+	Overlap [][]int      `json:"overlap"`
+}
+
+type pairedVote struct {
+	userId   int
+	userName string
+	roomId   int
+	mine     float64
+	theirs   float64
+}
+
+type roomRating struct {
+	userId   int
+	userName string
+	beerId   int
+	value    float64
+}
+
+// getPairedVotes returns every rating other users gave on published beers
+// the user also rated, next to the user's own rating. Ratings are mapped
+// onto 0-1 so pairs from rooms with different scales can be combined.
+// Anonymous rooms are left out, since a twin would give away who rated
+// what there.
+func (sr *StatsRepo) getPairedVotes(ctx context.Context, userId int) ([]pairedVote, error) {
+	rows, err := sr.db.QueryContext(ctx, `
+      SELECT
+        users.id,
+        IF(users.name != '', users.name, users.username),
+        rooms.id,
+        (mine.points - rooms.scale_min) / (rooms.scale_max - rooms.scale_min),
+        (votes.points - rooms.scale_min) / (rooms.scale_max - rooms.scale_min)
+      FROM votes AS mine
+      JOIN votes ON votes.beer_id = mine.beer_id AND votes.user_id != mine.user_id
+      JOIN beers ON beers.id = mine.beer_id
+      JOIN rooms ON rooms.id = beers.room_id
+      JOIN users ON users.id = votes.user_id
+      WHERE mine.user_id = ? AND beers.published = TRUE AND rooms.anonymity = 'off'
+    `,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paired := []pairedVote{}
+	for rows.Next() {
+		var p pairedVote
+		err := rows.Scan(&p.userId, &p.userName, &p.roomId, &p.mine, &p.theirs)
+		if err != nil {
+			return nil, err
+		}
+		paired = append(paired, p)
+	}
+	return paired, rows.Err()
+}
+
+// getRoomAnonymity also names the room's participants when it is
+// anonymous.
+func (sr *StatsRepo) getRoomAnonymity(ctx context.Context, roomId int) (beers.Anonymity, beers.Pseudonyms, error) {
+	var anonymity beers.Anonymity
+	var salt string
+	err := sr.db.QueryRowContext(ctx, `
+      SELECT anonymity, pseudonym_salt
+      FROM rooms
+      WHERE id = ?
+    `,
+		roomId,
+	).Scan(&anonymity, &salt)
+	if err != nil || anonymity == beers.AnonymityOff {
+		return anonymity, beers.Pseudonyms{}, err
+	}
+	names, err := beers.LoadPseudonyms(ctx, sr.db, roomId, salt)
+	return anonymity, names, err
+}
+
+func (sr *StatsRepo) getRoomRatings(ctx context.Context, roomId int, showUnpublished bool) ([]roomRating, error) {
+	rows, err := sr.db.QueryContext(ctx, `
+      SELECT
+        users.id,
+        IF(users.name != '', users.name, users.username) AS userName,
+        votes.beer_id,
+        votes.points
+      FROM votes
+      JOIN beers ON beers.id = votes.beer_id
+      JOIN users ON users.id = votes.user_id
+      WHERE beers.room_id = ? AND (beers.published = TRUE OR ?)
+      ORDER BY userName, users.id
+    `,
+		roomId,
+		showUnpublished,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := []roomRating{}
+	for rows.Next() {
+		var r roomRating
+		err := rows.Scan(&r.userId, &r.userName, &r.beerId, &r.value)
+		if err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, r)
+	}
+	return ratings, rows.Err()
+}
+
+// GetPalateTwins ranks everyone who rated the same published beers as the
+// user by how closely their ratings correlate.
+func (s *StatsService) GetPalateTwins(ctx context.Context, userId int, method string, minOverlap int) (*PalateTwins, error) {
+	paired, err := s.statsRepo.getPairedVotes(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	type pairs struct {
+		taster Taster
+		mine   []float64
+		theirs []float64
+		rooms  map[int]bool
+	}
+	byUser := map[int]*pairs{}
+	for _, p := range paired {
+		u, ok := byUser[p.userId]
+		if !ok {
+			u = &pairs{taster: Taster{UserId: p.userId, Name: p.userName}, rooms: map[int]bool{}}
+			byUser[p.userId] = u
+		}
+		u.mine = append(u.mine, p.mine)
+		u.theirs = append(u.theirs, p.theirs)
+		u.rooms[p.roomId] = true
+	}
+
+	twins := []PalateTwin{}
+	for _, u := range byUser {
+		if len(u.mine) < minOverlap {
+			continue
+		}
+		c := correlate(method, u.mine, u.theirs)
+		if c == nil {
+			continue
+		}
+		twins = append(twins, PalateTwin{
+			Taster:      u.taster,
+			Correlation: c,
+			Overlap:     len(u.mine),
+			SharedRooms: len(u.rooms),
+		})
+	}
+	sort.Slice(twins, func(i, j int) bool {
+		if *twins[i].Correlation != *twins[j].Correlation {
+			return *twins[i].Correlation > *twins[j].Correlation
+		}
+		if twins[i].Overlap != twins[j].Overlap {
+			return twins[i].Overlap > twins[j].Overlap
+		}
+		return twins[i].UserId < twins[j].UserId
+	})
+
+	return &PalateTwins{Method: method, MinOverlap: minOverlap, Twins: twins}, nil
+}
+
+// GetRoomSimilarity correlates every pair of tasters in a room. Without
+// showUnpublished only published beers are compared. Anonymous rooms list the
+// tasters by pseudonym or without names.
+func (s *StatsService) GetRoomSimilarity(ctx context.Context, roomId int, showUnpublished bool, method string, minOverlap int) (*SimilarityMatrix, error) {
+	ratings, err := s.statsRepo.getRoomRatings(ctx, roomId, showUnpublished)
+	if err != nil {
+		return nil, err
+	}
+	anonymity, names, err := s.statsRepo.getRoomAnonymity(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	if anonymity != beers.AnonymityOff {
+		// Ratings come ordered by real name, which would give away who is
+		// who, so they are reordered by pseudonym even when it is not shown.
+		sort.SliceStable(ratings, func(i, j int) bool {
+			return names.Name(ratings[i].userId) < names.Name(ratings[j].userId)
+		})
+		for i := range ratings {
+			r := &ratings[i]
+			_, r.userName = anonymity.Identity(names, r.userId, r.userName)
+		}
+	}
+
+	tasters := []Taster{}
+	index := map[int]int{}
+	byTaster := []map[int]float64{}
+	for _, r := range ratings {
+		i, ok := index[r.userId]
+		if !ok {
+			i = len(tasters)
+			index[r.userId] = i
+			taster := Taster{UserId: r.userId, Name: r.userName}
+			if anonymity != beers.AnonymityOff {
+				taster.UserId = 0
+			}
+			tasters = append(tasters, taster)
+			byTaster = append(byTaster, map[int]float64{})
+		}
+		byTaster[i][r.beerId] = r.value
+	}
+
+	n := len(tasters)
+	matrix := make([][]*float64, n)
+	overlap := make([][]int, n)
+	for i := range matrix {
+		matrix[i] = make([]*float64, n)
+		overlap[i] = make([]int, n)
+	}
+	for i := 0; i < n; i++ {
+		overlap[i][i] = len(byTaster[i])
+		if len(byTaster[i]) >= minOverlap {
+			one := 1.0
+			matrix[i][i] = &one
+		}
+		for j := i + 1; j < n; j++ {
+			var xs, ys []float64
+			for beerId, x := range byTaster[i] {
+				if y, ok := byTaster[j][beerId]; ok {
+					xs = append(xs, x)
+					ys = append(ys, y)
+				}
+			}
+			overlap[i][j], overlap[j][i] = len(xs), len(xs)
+			if len(xs) < minOverlap {
+				continue
+			}
+			c := correlate(method, xs, ys)
+			matrix[i][j], matrix[j][i] = c, c
+		}
+	}
+
+	return &SimilarityMatrix{
+		Method:     method,
+		MinOverlap: minOverlap,
+		Tasters:    tasters,
+		Matrix:     matrix,
+		Overlap:    overlap,
+	}, nil
+}
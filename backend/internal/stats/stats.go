@@ -0,0 +1,123 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+)
+
+type StatsRepo struct {
+	db *sql.DB
+}
+
+type StyleStats struct {
+	Style   string  `json:"style"`
+	Ratings int     `json:"ratings"`
+	Average float64 `json:"average"`
+	// Score is the average rating mapped onto 0-1 using each room's rating
+	// scale, so styles rated in different rooms can be compared.
+	Score float64 `json:"score"`
+}
+
+type totals struct {
+	tastings          int
+	ratings           int
+	average           *float64
+	averageNormalized *float64
+}
+
+type deviation struct {
+	average *float64
+	ratings int
+}
+
+func NewStatsRepo(db *sql.DB) *StatsRepo {
+	return &StatsRepo{
+		db: db,
+	}
+}
+
+// normalizedPoints maps a vote onto 0-1 using its room's rating scale.
+const normalizedPoints = `(votes.points - rooms.scale_min) / (rooms.scale_max - rooms.scale_min)`
+
+func (sr *StatsRepo) getTotals(ctx context.Context, userId int) (totals, error) {
+	var t totals
+	err := sr.db.QueryRowContext(ctx, `
+      SELECT
+        COUNT(DISTINCT beers.room_id),
+        COUNT(*),
+        AVG(votes.points),
+        AVG(`+normalizedPoints+`)
+      FROM votes
+      JOIN beers ON beers.id = votes.beer_id
+      JOIN rooms ON rooms.id = beers.room_id
+      WHERE votes.user_id = ?
+    `,
+		userId,
+	).Scan(&t.tastings, &t.ratings, &t.average, &t.averageNormalized)
+	return t, err
+}
+
+// getDeviation compares the user's ratings with everyone else's on the same
+// beers. Only published beers count, since other ratings are private until
+// then.
+func (sr *StatsRepo) getDeviation(ctx context.Context, userId int) (deviation, error) {
+	var d deviation
+	err := sr.db.QueryRowContext(ctx, `
+      SELECT
+        AVG((votes.points - others.average) / (rooms.scale_max - rooms.scale_min)),
+        COUNT(*)
+      FROM votes
+      JOIN beers ON beers.id = votes.beer_id
+      JOIN rooms ON rooms.id = beers.room_id
+      JOIN (
+        SELECT other.beer_id, AVG(other.points) AS average
+        FROM votes AS other
+        JOIN votes AS mine ON mine.beer_id = other.beer_id AND mine.user_id = ?
+        WHERE other.user_id != ?
+        GROUP BY other.beer_id
+      ) AS others ON others.beer_id = votes.beer_id
+      WHERE votes.user_id = ? AND beers.published = TRUE
+    `,
+		userId,
+		userId,
+		userId,
+	).Scan(&d.average, &d.ratings)
+	return d, err
+}
+
+// getStyleStats groups the user's ratings by style, ignoring case. Beers
+// still hidden in blind rooms are left out so their style is not revealed.
+func (sr *StatsRepo) getStyleStats(ctx context.Context, userId int) ([]StyleStats, error) {
+	rows, err := sr.db.QueryContext(ctx, `
+      SELECT
+        MIN(TRIM(beers.style)),
+        COUNT(*),
+        AVG(votes.points),
+        AVG(`+normalizedPoints+`) AS score
+      FROM votes
+      JOIN beers ON beers.id = votes.beer_id
+      JOIN rooms ON rooms.id = beers.room_id
+      WHERE votes.user_id = ?
+      AND beers.style IS NOT NULL AND TRIM(beers.style) != ''
+      AND (rooms.blind_mode = FALSE OR beers.revealed = TRUE)
+      GROUP BY LOWER(TRIM(beers.style))
+      ORDER BY score DESC, COUNT(*) DESC
+    `,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	styles := []StyleStats{}
+	for rows.Next() {
+		var s StyleStats
+		err := rows.Scan(&s.Style, &s.Ratings, &s.Average, &s.Score)
+		if err != nil {
+			return nil, err
+		}
+		styles = append(styles, s)
+	}
+	return styles, rows.Err()
+}
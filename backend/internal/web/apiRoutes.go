@@ -10,7 +10,12 @@ import (
 
 	"skafteresort.se/beers/internal/auth"
 	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/guesses"
+	"skafteresort.se/beers/internal/providers"
+	"skafteresort.se/beers/internal/recommendations"
+	"skafteresort.se/beers/internal/results"
 	"skafteresort.se/beers/internal/rooms"
+	"skafteresort.se/beers/internal/stats"
 )
 
 func addApiRoutes(
@@ -18,6 +23,11 @@ func addApiRoutes(
 	userService *auth.UserService,
 	roomService *rooms.RoomService,
 	beerService *beers.BeerService,
+	guessService *guesses.GuessService,
+	resultsService *results.ResultsService,
+	statsService *stats.StatsService,
+	recommendationService *recommendations.RecommendationService,
+	imageProvider *providers.ImageProvider,
 ) *http.ServeMux {
 
 	mux := http.NewServeMux()
@@ -47,6 +57,66 @@ func addApiRoutes(
 		handleEditRoom(roomService, logger),
 	)
 
+	mux.Handle(
+		"/api/room/{room}/settings",
+		handleUpdateRoomSettings(roomService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/rubric",
+		handleGetRubric(roomService, beerService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/rubric/edit",
+		handleEditRubric(roomService, beerService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/state",
+		handleUpdateRoomState(roomService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/join-requests",
+		handleGetJoinRequests(roomService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/join-requests/{request}/approve",
+		handleApproveJoinRequest(roomService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/join-requests/{request}/reject",
+		handleRejectJoinRequest(roomService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/delete/token",
+		handleCreateDeleteRoomToken(roomService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/delete",
+		handleDeleteRoom(roomService, recommendationService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/clone",
+		handleCloneRoom(roomService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/archive",
+		handleArchiveRoom(roomService, logger, true),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/unarchive",
+		handleArchiveRoom(roomService, logger, false),
+	)
+
 	mux.Handle(
 		"/api/room/{room}/leave",
 		handleLeaveRoom(roomService, logger),
@@ -57,6 +127,11 @@ func addApiRoutes(
 		handleCheckIfUserIsAdminInRoom(roomService, logger),
 	)
 
+	mux.Handle(
+		"/api/room/{room}/role",
+		handleGetMyRoleInRoom(roomService, logger),
+	)
+
 	mux.Handle(
 		"/api/room/{room}/beers/{beer}/my-rating",
 		handleGetMyRatingForBeer(roomService, beerService, logger),
@@ -67,9 +142,19 @@ func addApiRoutes(
 		handleGetRatingsForBeer(roomService, beerService, logger),
 	)
 
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/history",
+		handleGetVoteHistory(roomService, beerService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/flavor-profile",
+		handleGetFlavorProfile(roomService, beerService, logger),
+	)
+
 	mux.Handle(
 		"/api/room/{room}/beers/{beer}/edit",
-		handleEditBeer(roomService, beerService, logger),
+		handleEditBeer(roomService, beerService, guessService, recommendationService, logger),
 	)
 
 	mux.Handle(
@@ -78,8 +163,13 @@ func addApiRoutes(
 	)
 
 	mux.Handle(
-		"/api/room/{room}/users/{user}/admin",
-		handleUpdateIsAdminForUser(roomService, logger),
+		"/api/room/{room}/users/{user}/role",
+		handleUpdateRoleForUser(roomService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/transfer-ownership",
+		handleTransferOwnership(roomService, logger),
 	)
 
 	mux.Handle(
@@ -94,7 +184,7 @@ func addApiRoutes(
 
 	mux.Handle(
 		"/api/room/{room}/beers/new",
-		handleAddBeer(beerService, logger),
+		handleAddBeer(beerService, roomService, logger),
 	)
 
 	mux.Handle(
@@ -114,17 +204,112 @@ func addApiRoutes(
 
 	mux.Handle(
 		"/api/room/{room}/beers/{beer}/publish",
-		handlePublishRatingsForBeer(roomService, beerService, logger),
+		handlePublishRatingsForBeer(roomService, beerService, recommendationService, logger),
 	)
 
 	mux.Handle(
 		"/api/room/{room}/beers/{beer}/unpublish",
-		handleUnpublishRatingsForBeer(roomService, beerService, logger),
+		handleUnpublishRatingsForBeer(roomService, beerService, recommendationService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/progress",
+		handleGetVotingProgress(roomService, beerService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/voters",
+		handleGetVoters(roomService, beerService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/voting/close",
+		handleSetVotingClosed(roomService, beerService, true, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/voting/open",
+		handleSetVotingClosed(roomService, beerService, false, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/voting/deadline",
+		handleSetBeerVotingDeadline(roomService, beerService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/voting/deadline",
+		handleSetRoomVotingDeadline(roomService, beerService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/votes/sync",
+		handleSyncVotes(beerService, roomService, recommendationService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/reveal",
+		handleRevealBeer(roomService, beerService, guessService, recommendationService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/reveal-all",
+		handleRevealAllBeers(roomService, beerService, guessService, recommendationService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/guess",
+		handleSubmitGuesses(roomService, guessService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/beers/{beer}/my-guesses",
+		handleGetMyGuesses(roomService, guessService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/guessing/rules",
+		handleGetGuessRules(roomService, guessService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/guessing/rules/edit",
+		handleEditGuessRules(roomService, guessService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/results",
+		handleGetResults(roomService, resultsService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/similarity",
+		handleGetRoomSimilarity(roomService, statsService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/export",
+		handleExportRoom(roomService, resultsService, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/report",
+		handleGetReport(roomService, resultsService, imageProvider, logger),
+	)
+
+	mux.Handle(
+		"/api/room/{room}/guessing/leaderboard",
+		handleGetGuessLeaderboard(roomService, guessService, logger),
 	)
 
 	mux.Handle(
 		"/api/room/{room}/beers/{beer}/rate",
-		handleVoteOnBeer(beerService, roomService, logger),
+		handleVoteOnBeer(beerService, roomService, recommendationService, logger),
+	)
+
+	mux.Handle(
+		"/api/descriptors",
+		handleGetDescriptors(beerService),
 	)
 
 	mux.Handle(
@@ -132,6 +317,26 @@ func addApiRoutes(
 		handleGetUserProfile(userService, logger),
 	)
 
+	mux.Handle(
+		"/api/user/journal",
+		handleGetJournal(beerService, logger),
+	)
+
+	mux.Handle(
+		"/api/user/stats",
+		handleGetUserStats(statsService, logger),
+	)
+
+	mux.Handle(
+		"/api/user/palate-twins",
+		handleGetPalateTwins(statsService, logger),
+	)
+
+	mux.Handle(
+		"/api/user/recommendations",
+		handleGetRecommendations(recommendationService, logger),
+	)
+
 	mux.Handle(
 		"/api/user/updateProfile",
 		handleUpdateUserProfile(userService, logger),
@@ -152,7 +357,9 @@ func handleRooms(
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			userId := r.Context().Value(ContextUserKey)
-			rooms, err := rs.GetRoomsByUserId(r.Context(), userId.(int))
+			// Archived rooms are hidden unless explicitly asked for.
+			archived, _ := strconv.ParseBool(r.URL.Query().Get("archived"))
+			rooms, err := rs.GetRoomsByUserId(r.Context(), userId.(int), archived)
 			if err != nil {
 				if err.Error() == "Unauthorized" {
 					logger.Error("handleRooms", "err", err)
@@ -183,11 +390,17 @@ func handleEditRoom(
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
-			if ok, err := rs.CheckIfUserIsAdminInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageRoom); !ok || err != nil {
 				logger.Error("handleEditRoom", "err", err)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionEditRoom); !ok || err != nil {
+				logger.Error("handleEditRoom/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
 			var data rooms.Room
 
 			err = json.NewDecoder(r.Body).Decode(&data)
@@ -215,6 +428,136 @@ func handleEditRoom(
 	)
 }
 
+func handleUpdateRoomSettings(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleUpdateRoomSettings/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageRoom); !ok || err != nil {
+				logger.Error("handleUpdateRoomSettings", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionEditRoom); !ok || err != nil {
+				logger.Error("handleUpdateRoomSettings/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			room, err := rs.GetRoomById(r.Context(), roomId)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					logger.Error("handleUpdateRoomSettings/room", "err", "Room not found")
+					http.Error(w, "Room not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("handleUpdateRoomSettings/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			// Decode on top of the current settings so that clients only have to
+			// send the settings they want to change.
+			settings := room.Settings
+			err = json.NewDecoder(r.Body).Decode(&settings)
+			if err != nil {
+				logger.Error("handleUpdateRoomSettings/decode", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			if err := settings.Valid(); err != nil {
+				logger.Error("handleUpdateRoomSettings/valid", "err", err)
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+
+			err = rs.UpdateRoomSettings(r.Context(), roomId, settings)
+			if err != nil {
+				logger.Error("handleUpdateRoomSettings/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(settings)
+		},
+	)
+}
+
+func handleUpdateRoomState(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleUpdateRoomState/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			role, err := rs.GetRoleInRoom(r.Context(), roomId, userId.(int))
+			if err != nil || !role.Can(rooms.PermissionManageRoom) {
+				logger.Error("handleUpdateRoomState", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			var data struct {
+				State rooms.State `json:"state"`
+			}
+			err = json.NewDecoder(r.Body).Decode(&data)
+			if err != nil {
+				logger.Error("handleUpdateRoomState/decode", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			room, err := rs.GetRoomById(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleUpdateRoomState/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if !room.State.CanTransitionTo(data.State) {
+				logger.Error("handleUpdateRoomState", "from", room.State, "to", data.State)
+				http.Error(w, "Invalid state transition", http.StatusUnprocessableEntity)
+				return
+			}
+
+			// Finished and archived rooms are read-only for everyone but the owner.
+			if (room.State == rooms.StateFinished || room.State == rooms.StateArchived) && role != rooms.RoleOwner {
+				logger.Error("handleUpdateRoomState", "err", "only owner can reopen", "state", room.State)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			err = rs.UpdateRoomState(r.Context(), roomId, room.State, data.State)
+			if err != nil {
+				logger.Error("handleUpdateRoomState/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(data)
+		},
+	)
+}
+
 func handleRoom(
 	rs *rooms.RoomService,
 	logger *slog.Logger,
@@ -277,7 +620,7 @@ func handleUsersInRoom(
 	)
 }
 
-func handleUpdateIsAdminForUser(
+func handleUpdateRoleForUser(
 	rs *rooms.RoomService,
 	logger *slog.Logger,
 ) http.Handler {
@@ -286,46 +629,74 @@ func handleUpdateIsAdminForUser(
 			userId := r.Context().Value(ContextUserKey)
 			roomId, err := strconv.Atoi(r.PathValue("room"))
 			if err != nil {
-				logger.Error("handleIsAdminForUser", "err", err)
+				logger.Error("handleUpdateRoleForUser", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
 
 			targetUserId, err := strconv.Atoi(r.PathValue("user"))
 			if err != nil {
-				logger.Error("handleIsAdminForUser", "err", err)
+				logger.Error("handleUpdateRoleForUser", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
 
-			if ok, err := rs.CheckIfUserIsAdminInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
-				logger.Error("handlePublishRatingsForBeer", "err", err)
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageMembers); !ok || err != nil {
+				logger.Error("handleUpdateRoleForUser", "err", err)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
 			var data struct {
-				IsAdmin bool `json:"isAdmin"`
+				Role rooms.Role `json:"role"`
 			}
 
 			err = json.NewDecoder(r.Body).Decode(&data)
 			if err != nil {
-				logger.Error("handleIsAdminForUser", "err", err)
+				logger.Error("handleUpdateRoleForUser", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
 
-			if !data.IsAdmin {
-				if ok, err := rs.CheckIfOtherAdminInRoom(r.Context(), roomId, targetUserId); !ok || err != nil {
-					logger.Error("handleGetSingleBeer", "err", err)
-					http.Error(w, "Only admin left", http.StatusUnprocessableEntity)
+			if !data.Role.Valid() || data.Role == rooms.RoleOwner {
+				logger.Error("handleUpdateRoleForUser", "err", "invalid role", "role", data.Role)
+				http.Error(w, "Invalid role", http.StatusUnprocessableEntity)
+				return
+			}
+
+			targetRole, err := rs.GetRoleInRoom(r.Context(), roomId, targetUserId)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					logger.Error("handleUpdateRoleForUser", "err", "Not in room")
+					http.Error(w, "Not in room", http.StatusUnprocessableEntity)
 					return
 				}
+				logger.Error("handleUpdateRoleForUser", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
 			}
 
-			err = rs.UpdateIsAdmin(r.Context(), roomId, targetUserId, data.IsAdmin)
+			if targetRole == rooms.RoleOwner {
+				logger.Error("handleUpdateRoleForUser", "err", "cannot change owner role")
+				http.Error(w, "Ownership must be transferred", http.StatusUnprocessableEntity)
+				return
+			}
+
+			role, err := rs.GetRoleInRoom(r.Context(), roomId, userId.(int))
+			if err != nil {
+				logger.Error("handleUpdateRoleForUser", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if !role.Outranks(targetRole) || !role.Outranks(data.Role) {
+				logger.Error("handleUpdateRoleForUser", "err", "role not outranked", "role", role, "target", targetRole, "new", data.Role)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			err = rs.UpdateRole(r.Context(), roomId, targetUserId, data.Role)
 			if err != nil {
-				logger.Error("handleIsAdminForUser", "err", err)
+				logger.Error("handleUpdateRoleForUser", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
@@ -336,6 +707,94 @@ func handleUpdateIsAdminForUser(
 	)
 }
 
+func handleTransferOwnership(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleTransferOwnership", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionTransferOwnership); !ok || err != nil {
+				logger.Error("handleTransferOwnership", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			var data struct {
+				UserId int `json:"userId"`
+			}
+
+			err = json.NewDecoder(r.Body).Decode(&data)
+			if err != nil {
+				logger.Error("handleTransferOwnership", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if data.UserId == userId.(int) {
+				http.Error(w, "Already owner", http.StatusUnprocessableEntity)
+				return
+			}
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, data.UserId); !ok || err != nil {
+				logger.Error("handleTransferOwnership", "err", err)
+				http.Error(w, "Not in room", http.StatusUnprocessableEntity)
+				return
+			}
+
+			err = rs.TransferOwnership(r.Context(), roomId, userId.(int), data.UserId)
+			if err != nil {
+				logger.Error("handleTransferOwnership", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode("Success")
+		},
+	)
+}
+
+func handleGetMyRoleInRoom(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetMyRoleInRoom", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			userId := r.Context().Value(ContextUserKey)
+			role, err := rs.GetRoleInRoom(r.Context(), roomId, userId.(int))
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				logger.Error("handleGetMyRoleInRoom", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"role":        role,
+				"permissions": role.Permissions(),
+			})
+		},
+	)
+}
+
 func handleCheckIfUserIsAdminInRoom(
 	rs *rooms.RoomService,
 	logger *slog.Logger,
@@ -349,7 +808,7 @@ func handleCheckIfUserIsAdminInRoom(
 				return
 			}
 			userId := r.Context().Value(ContextUserKey)
-			isAdmin, err := rs.CheckIfUserIsAdminInRoom(r.Context(), roomId, userId.(int))
+			isAdmin, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup)
 			if err != nil {
 				logger.Error("handleCheckIfUserIsAdminInRoom", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -376,7 +835,14 @@ func handleBeersInRoom(
 				return
 			}
 
-			beers, err := rs.GetBeersInRoom(r.Context(), roomId)
+			showHidden, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup)
+			if err != nil {
+				logger.Error("handleBeersInRoom", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			beers, err := rs.GetBeersInRoom(r.Context(), roomId, showHidden)
 			if err != nil {
 				if err.Error() == "Unauthorized" {
 					logger.Error("handleRooms", "err", err)
@@ -395,15 +861,28 @@ func handleBeersInRoom(
 
 func handleAddBeer(
 	bs *beers.BeerService,
+	rs *rooms.RoomService,
 	logger *slog.Logger,
 ) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
 			roomId, _ := strconv.Atoi(r.PathValue("room"))
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); !ok || err != nil {
+				logger.Error("handleAddBeer", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionEditLineup); !ok || err != nil {
+				logger.Error("handleAddBeer/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
 			var beer beers.Beer
 			json.NewDecoder(r.Body).Decode(&beer)
 			logger.Info("HandleAddBeer", "beer", beer)
-			err := bs.AddNewBeer(r.Context(), beer.Name, beer.Style, beer.PictureUrl, roomId)
+			err := bs.AddNewBeer(r.Context(), beer, roomId)
 
 			if err != nil {
 				logger.Error("handleBeersInRoom", "err", err)
@@ -433,7 +912,7 @@ func handleRemoveUserFromRoom(
 				return
 			}
 
-			if ok, err := rs.CheckIfUserIsAdminInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageMembers); !ok || err != nil {
 				logger.Error("handleGetSingleBeer", "err", err)
 				http.Error(w, "Not in room", http.StatusUnprocessableEntity)
 				return
@@ -445,6 +924,25 @@ func handleRemoveUserFromRoom(
 				return
 			}
 
+			targetRole, err := rs.GetRoleInRoom(r.Context(), roomId, targetUserId)
+			if targetRole == rooms.RoleOwner || err != nil {
+				logger.Error("handleRemoveUserFromRoom", "err", err)
+				http.Error(w, "Owner cannot be removed", http.StatusUnprocessableEntity)
+				return
+			}
+
+			role, err := rs.GetRoleInRoom(r.Context(), roomId, userId.(int))
+			if err != nil {
+				logger.Error("handleRemoveUserFromRoom", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if !role.Outranks(targetRole) {
+				logger.Error("handleRemoveUserFromRoom", "err", "role not outranked", "role", role, "target", targetRole)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
 			err = rs.RemoveUserFromRoom(r.Context(), roomId, targetUserId)
 			if err != nil {
 				logger.Error("handleJoinRoom", "err", err)
@@ -482,9 +980,9 @@ func handleLeaveRoom(
 			}
 
 			logger.Info("LeaveRoom", "userId", userId.(int), "roomId", roomId)
-			if ok, err := rs.CheckIfOtherAdminInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
-				logger.Error("handleGetSingleBeer", "err", err)
-				http.Error(w, "Only admin left", http.StatusUnprocessableEntity)
+			if role, err := rs.GetRoleInRoom(r.Context(), roomId, userId.(int)); role == rooms.RoleOwner || err != nil {
+				logger.Error("handleLeaveRoom", "err", err)
+				http.Error(w, "Owner must transfer ownership before leaving", http.StatusUnprocessableEntity)
 				return
 			}
 
@@ -532,9 +1030,35 @@ func handleJoinRoom(
 				http.Error(w, "Already in room", http.StatusUnprocessableEntity)
 				return
 			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), room.Id, userId.(int), rooms.ActionJoin); !ok || err != nil {
+				logger.Error("handleJoinRoom/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
 			logger.Info("handleJoinRoom", "user", userId.(int), "room", room)
 
-			err = rs.AddUserToRoom(r.Context(), room.Id, userId.(int), false)
+			if room.Settings.RequireApproval {
+				if pending, err := rs.CheckIfPendingJoinRequest(r.Context(), room.Id, userId.(int)); pending || err != nil {
+					logger.Error("handleJoinRoom/pending", "err", err)
+					http.Error(w, "Join request already pending", http.StatusUnprocessableEntity)
+					return
+				}
+
+				jr, err := rs.CreateJoinRequest(r.Context(), room.Id, userId.(int))
+				if err != nil {
+					logger.Error("handleJoinRoom/request", "err", err)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusAccepted)
+				json.NewEncoder(w).Encode(jr)
+				return
+			}
+
+			err = rs.AddUserToRoom(r.Context(), room.Id, userId.(int), rooms.RoleTaster)
 			if err != nil {
 				logger.Error("handleJoinRoom", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -554,13 +1078,18 @@ func handleCreateRoom(
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			userId := r.Context().Value(ContextUserKey)
-			var room rooms.Room
+			room := rooms.Room{Settings: rooms.DefaultRoomSettings()}
 			err := json.NewDecoder(r.Body).Decode(&room)
 			if err != nil {
 				logger.Error("handleCreateRoom", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
+			if err := room.Settings.Valid(); err != nil {
+				logger.Error("handleCreateRoom/settings", "err", err)
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
 			id, err := rs.CreateNewRoom(r.Context(), userId.(int), room)
 			if err != nil {
 				logger.Error("handleCreateRoom", "err", err)
@@ -607,12 +1136,17 @@ func handleGetSingleBeer(
 			}
 
 			isAdmin := false
-			if ok, err := rs.CheckIfUserIsAdminInRoom(r.Context(), roomId, userId.(int)); ok && err == nil {
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); ok && err == nil {
 				isAdmin = true
 			}
 
 			beer, err := bs.GetBeerById(r.Context(), beerId, roomId, isAdmin)
 			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					logger.Error("handleGetSingleBeer", "err", "Beer not found")
+					http.Error(w, "Beer not found", http.StatusNotFound)
+					return
+				}
 				logger.Error("handleGetSingleBeer", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
@@ -705,7 +1239,18 @@ func handleGetRatingsForBeer(
 				return
 			}
 
-			ratings, err := bs.GetVotesByBeerId(r.Context(), beerId, roomId)
+			if ok, err := rs.CheckIfBeerInRoom(r.Context(), roomId, beerId); !ok || err != nil {
+				logger.Error("handleGetRatingsForBeer", "err", err)
+				http.Error(w, "Beer not found", http.StatusNotFound)
+				return
+			}
+
+			isAdmin := false
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); ok && err == nil {
+				isAdmin = true
+			}
+
+			ratings, err := bs.GetVotesByBeerId(r.Context(), beerId, roomId, userId.(int), isAdmin)
 			if err != nil {
 				logger.Error("handleGetRatingsForBeer", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -720,6 +1265,7 @@ func handleGetRatingsForBeer(
 func handleUnpublishRatingsForBeer(
 	rs *rooms.RoomService,
 	bs *beers.BeerService,
+	recs *recommendations.RecommendationService,
 	logger *slog.Logger,
 ) http.Handler {
 	return http.HandlerFunc(
@@ -734,12 +1280,18 @@ func handleUnpublishRatingsForBeer(
 
 			userId := r.Context().Value(ContextUserKey)
 
-			if ok, err := rs.CheckIfUserIsAdminInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionPublish); !ok || err != nil {
 				logger.Error("handlePublishRatingsForBeer", "err", err)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionPublish); !ok || err != nil {
+				logger.Error("handleUnpublishRatingsForBeer/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
 			beerId, err := strconv.Atoi(r.PathValue("beer"))
 
 			if err != nil {
@@ -754,6 +1306,9 @@ func handleUnpublishRatingsForBeer(
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
+			if err := recs.RefreshBeer(r.Context(), beerId); err != nil {
+				logger.Error("handleUnpublishRatingsForBeer/recommendations", "err", err)
+			}
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode("Published")
 		},
@@ -763,6 +1318,7 @@ func handleUnpublishRatingsForBeer(
 func handlePublishRatingsForBeer(
 	rs *rooms.RoomService,
 	bs *beers.BeerService,
+	recs *recommendations.RecommendationService,
 	logger *slog.Logger,
 ) http.Handler {
 	return http.HandlerFunc(
@@ -777,12 +1333,18 @@ func handlePublishRatingsForBeer(
 
 			userId := r.Context().Value(ContextUserKey)
 
-			if ok, err := rs.CheckIfUserIsAdminInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionPublish); !ok || err != nil {
 				logger.Error("handlePublishRatingsForBeer", "err", err)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionPublish); !ok || err != nil {
+				logger.Error("handlePublishRatingsForBeer/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
 			beerId, err := strconv.Atoi(r.PathValue("beer"))
 
 			if err != nil {
@@ -797,6 +1359,9 @@ func handlePublishRatingsForBeer(
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
+			if err := recs.RefreshBeer(r.Context(), beerId); err != nil {
+				logger.Error("handlePublishRatingsForBeer/recommendations", "err", err)
+			}
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode("Published")
 		},
@@ -806,6 +1371,7 @@ func handlePublishRatingsForBeer(
 func handleVoteOnBeer(
 	bs *beers.BeerService,
 	rs *rooms.RoomService,
+	recs *recommendations.RecommendationService,
 	logger *slog.Logger,
 ) http.Handler {
 	return http.HandlerFunc(
@@ -820,12 +1386,18 @@ func handleVoteOnBeer(
 
 			userId := r.Context().Value(ContextUserKey)
 
-			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionRate); !ok || err != nil {
 				logger.Error("handleVoteOnBeer", "err", err)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionVote); !ok || err != nil {
+				logger.Error("handleVoteOnBeer/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
 			beerId, err := strconv.Atoi(r.PathValue("beer"))
 
 			if err != nil {
@@ -850,13 +1422,43 @@ func handleVoteOnBeer(
 			logger.Info("handleVoteOnBeer", "vote", vote)
 			vote.UserId = userId.(int)
 			vote.BeerId = beerId
+			if version, ok, err := parseIfMatch(r); err != nil {
+				logger.Error("handleVoteOnBeer", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			} else if ok {
+				vote.Version = version
+			}
 
-			err = bs.UpdateVoteOnBeerId(r.Context(), vote)
+			saved, err := bs.UpdateVoteOnBeerId(r.Context(), vote)
 			if err != nil {
+				if errors.As(err, &beers.InvalidRatingError{}) {
+					logger.Error("handleVoteOnBeer", "err", err)
+					http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+					return
+				}
+				var closedErr beers.VotingClosedError
+				if errors.As(err, &closedErr) {
+					logger.Error("handleVoteOnBeer", "err", err)
+					writeVotingClosed(w, closedErr)
+					return
+				}
+				var conflictErr beers.VersionConflictError
+				if errors.As(err, &conflictErr) {
+					logger.Error("handleVoteOnBeer", "err", err)
+					writeVersionConflict(w, conflictErr)
+					return
+				}
 				logger.Error("handleVoteOnBeer", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
+			// A failed refresh only leaves recommendations stale until the
+			// beer changes again, so it does not fail the vote.
+			if err := recs.RefreshBeer(r.Context(), beerId); err != nil {
+				logger.Error("handleVoteOnBeer/recommendations", "err", err)
+			}
+			w.Header().Set("ETag", voteETag(saved.Version))
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode("Success")
 		},
@@ -879,7 +1481,7 @@ func handleGetRandomBeer(
 
 			userId := r.Context().Value(ContextUserKey)
 
-			if ok, err := rs.CheckIfUserIsAdminInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); !ok || err != nil {
 				logger.Error("handleGetRandomBeer", "err", err)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
@@ -924,7 +1526,7 @@ func handleGetNextBeer(
 
 			userId := r.Context().Value(ContextUserKey)
 
-			if ok, err := rs.CheckIfUserIsAdminInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); !ok || err != nil {
 				logger.Error("handleGetNextBeer", "err", err)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
@@ -947,6 +1549,8 @@ func handleGetNextBeer(
 func handleEditBeer(
 	rs *rooms.RoomService,
 	bs *beers.BeerService,
+	gs *guesses.GuessService,
+	recs *recommendations.RecommendationService,
 	logger *slog.Logger,
 ) http.Handler {
 	return http.HandlerFunc(
@@ -965,27 +1569,55 @@ func handleEditBeer(
 				return
 			}
 
-			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); !ok || err != nil {
 				logger.Error("handleEditRoom", "err", err)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
-			var data beers.Beer
 
-			err = json.NewDecoder(r.Body).Decode(&data)
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionEditLineup); !ok || err != nil {
+				logger.Error("handleEditBeer/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+			// Decode over the stored beer so fields the client does not send,
+			// such as brewery and abv, are kept.
+			data, err := bs.GetBeer(r.Context(), beerId, roomId)
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Not Found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				logger.Error("handleEditBeer/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			err = json.NewDecoder(r.Body).Decode(data)
 			if err != nil {
 				logger.Error("handleEditRoom/strconv", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
 			data.Id = beerId
-			err = bs.UpdateBeer(r.Context(), data, roomId)
+			err = bs.UpdateBeer(r.Context(), *data, roomId)
 			if err != nil {
 				logger.Error("handleEditRoom/db", "err", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
 
+			// Correcting a revealed beer changes the answers guesses were
+			// scored against.
+			if data.Revealed {
+				if err := gs.ScoreRoom(r.Context(), roomId); err != nil {
+					logger.Error("handleEditBeer/score", "err", err)
+				}
+			}
+			if err := recs.RefreshBeer(r.Context(), beerId); err != nil {
+				logger.Error("handleEditBeer/recommendations", "err", err)
+			}
+
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode("Success")
 		},
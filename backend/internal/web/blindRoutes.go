@@ -0,0 +1,128 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/guesses"
+	"skafteresort.se/beers/internal/recommendations"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+func handleRevealBeer(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	gs *guesses.GuessService,
+	recs *recommendations.RecommendationService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleRevealBeer", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); !ok || err != nil {
+				logger.Error("handleRevealBeer", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionPublish); !ok || err != nil {
+				logger.Error("handleRevealBeer/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			beerId, err := strconv.Atoi(r.PathValue("beer"))
+			if err != nil {
+				logger.Error("handleRevealBeer", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckIfBeerInRoom(r.Context(), roomId, beerId); !ok || err != nil {
+				logger.Error("handleRevealBeer", "err", err)
+				http.Error(w, "Beer not found", http.StatusNotFound)
+				return
+			}
+
+			err = bs.RevealBeer(r.Context(), beerId, roomId)
+			if err != nil {
+				logger.Error("handleRevealBeer", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			// The beer is revealed either way, so a scoring failure is only
+			// logged; the next reveal or rule change scores the room again.
+			if err := gs.ScoreRoom(r.Context(), roomId); err != nil {
+				logger.Error("handleRevealBeer/score", "err", err)
+			}
+			if err := recs.RefreshBeer(r.Context(), beerId); err != nil {
+				logger.Error("handleRevealBeer/recommendations", "err", err)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode("Revealed")
+		},
+	)
+}
+
+func handleRevealAllBeers(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	gs *guesses.GuessService,
+	recs *recommendations.RecommendationService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleRevealAllBeers", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); !ok || err != nil {
+				logger.Error("handleRevealAllBeers", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionPublish); !ok || err != nil {
+				logger.Error("handleRevealAllBeers/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			err = bs.RevealAllBeers(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleRevealAllBeers", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if err := gs.ScoreRoom(r.Context(), roomId); err != nil {
+				logger.Error("handleRevealAllBeers/score", "err", err)
+			}
+			if err := recs.RefreshRoom(r.Context(), roomId); err != nil {
+				logger.Error("handleRevealAllBeers/recommendations", "err", err)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode("Revealed")
+		},
+	)
+}
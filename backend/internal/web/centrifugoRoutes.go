@@ -1,16 +1,72 @@
 package web
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"skafteresort.se/beers/internal/rooms"
 )
 
-func handleSubscriptionToken(logger *slog.Logger, hmacKey string) http.Handler {
+// canSubscribe decides who gets a token for a channel. Room and beer
+// channels are open to the room's members, the hosts channel only to those
+// who manage the lineup.
+func canSubscribe(ctx context.Context, rs *rooms.RoomService, userId int, channel string) (bool, error) {
+	if rest, ok := strings.CutPrefix(channel, "beers:room-"); ok {
+		if id, ok := strings.CutSuffix(rest, "-hosts"); ok {
+			roomId, err := strconv.Atoi(id)
+			if err != nil {
+				return false, nil
+			}
+			return rs.CheckPermission(ctx, roomId, userId, rooms.PermissionManageLineup)
+		}
+		roomId, err := strconv.Atoi(rest)
+		if err != nil {
+			return false, nil
+		}
+		return rs.CheckIfUserInRoom(ctx, roomId, userId)
+	}
+
+	if rest, ok := strings.CutPrefix(channel, "beers:beer-"); ok {
+		beerId, err := strconv.Atoi(rest)
+		if err != nil {
+			return false, nil
+		}
+		roomId, err := rs.GetRoomIdForBeer(ctx, beerId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return rs.CheckIfUserInRoom(ctx, roomId, userId)
+	}
+
+	if rest, ok := strings.CutPrefix(channel, "rooms:"); ok {
+		id, ok := strings.CutSuffix(rest, "-next-beer")
+		if !ok {
+			return false, nil
+		}
+		roomId, err := strconv.Atoi(id)
+		if err != nil {
+			return false, nil
+		}
+		return rs.CheckIfUserInRoom(ctx, roomId, userId)
+	}
+
+	return false, nil
+}
+
+func handleSubscriptionToken(rs *rooms.RoomService, logger *slog.Logger, hmacKey string) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			userId := r.Context().Value(ContextUserKey)
@@ -32,6 +88,13 @@ func handleSubscriptionToken(logger *slog.Logger, hmacKey string) http.Handler {
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
+
+			if ok, err := canSubscribe(r.Context(), rs, userId.(int), data.Channel); !ok || err != nil {
+				logger.Error("handleSubscriptionToken", "err", err, "channel", data.Channel)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
 			token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 				"sub":     fmt.Sprintf("%d", userId.(int)),
 				"channel": data.Channel,
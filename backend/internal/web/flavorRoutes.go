@@ -0,0 +1,82 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+func handleGetDescriptors(
+	bs *beers.BeerService,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(bs.GetTaxonomy())
+		},
+	)
+}
+
+func handleGetFlavorProfile(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetFlavorProfile", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleGetFlavorProfile", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			beerId, err := strconv.Atoi(r.PathValue("beer"))
+			if err != nil {
+				logger.Error("handleGetFlavorProfile", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckIfBeerInRoom(r.Context(), roomId, beerId); !ok || err != nil {
+				logger.Error("handleGetFlavorProfile", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// The profile aggregates everyone's votes, so it follows the same
+			// visibility as the ratings themselves.
+			isAdmin := false
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); ok && err == nil {
+				isAdmin = true
+			}
+			if ok, err := bs.CanSeeAllRatings(r.Context(), beerId, isAdmin); !ok || err != nil {
+				logger.Error("handleGetFlavorProfile/visibility", "err", err)
+				http.Error(w, "Ratings are not published yet", http.StatusForbidden)
+				return
+			}
+
+			profile, err := bs.GetFlavorProfile(r.Context(), beerId)
+			if err != nil {
+				logger.Error("handleGetFlavorProfile", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(profile)
+		},
+	)
+}
@@ -0,0 +1,260 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"skafteresort.se/beers/internal/guesses"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+func handleSubmitGuesses(
+	rs *rooms.RoomService,
+	gs *guesses.GuessService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleSubmitGuesses", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			beerId, err := strconv.Atoi(r.PathValue("beer"))
+			if err != nil {
+				logger.Error("handleSubmitGuesses", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionRate); !ok || err != nil {
+				logger.Error("handleSubmitGuesses", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionVote); !ok || err != nil {
+				logger.Error("handleSubmitGuesses/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			var data []guesses.Guess
+			err = json.NewDecoder(r.Body).Decode(&data)
+			if err != nil {
+				logger.Error("handleSubmitGuesses/decode", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			err = gs.SubmitGuesses(r.Context(), beerId, roomId, userId.(int), data)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					http.Error(w, "Beer not found", http.StatusNotFound)
+					return
+				}
+				if errors.As(err, &guesses.InvalidGuessError{}) {
+					logger.Error("handleSubmitGuesses", "err", err)
+					http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+					return
+				}
+				if errors.As(err, &guesses.ClosedError{}) {
+					logger.Error("handleSubmitGuesses", "err", err)
+					http.Error(w, err.Error(), http.StatusConflict)
+					return
+				}
+				logger.Error("handleSubmitGuesses/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode("Success")
+		},
+	)
+}
+
+func handleGetMyGuesses(
+	rs *rooms.RoomService,
+	gs *guesses.GuessService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetMyGuesses", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			beerId, err := strconv.Atoi(r.PathValue("beer"))
+			if err != nil {
+				logger.Error("handleGetMyGuesses", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleGetMyGuesses", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if ok, err := rs.CheckIfBeerInRoom(r.Context(), roomId, beerId); !ok || err != nil {
+				logger.Error("handleGetMyGuesses", "err", err)
+				http.Error(w, "Beer not found", http.StatusNotFound)
+				return
+			}
+
+			myGuesses, err := gs.GetMyGuesses(r.Context(), beerId, userId.(int))
+			if err != nil {
+				logger.Error("handleGetMyGuesses/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(myGuesses)
+		},
+	)
+}
+
+func handleGetGuessRules(
+	rs *rooms.RoomService,
+	gs *guesses.GuessService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetGuessRules", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleGetGuessRules", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			rules, err := gs.GetRules(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleGetGuessRules/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(rules)
+		},
+	)
+}
+
+func handleEditGuessRules(
+	rs *rooms.RoomService,
+	gs *guesses.GuessService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleEditGuessRules", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageRoom); !ok || err != nil {
+				logger.Error("handleEditGuessRules", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionEditRoom); !ok || err != nil {
+				logger.Error("handleEditGuessRules/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			var rules guesses.Rules
+			err = json.NewDecoder(r.Body).Decode(&rules)
+			if err != nil {
+				logger.Error("handleEditGuessRules/decode", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			err = gs.SetRules(r.Context(), roomId, rules)
+			if err != nil {
+				if errors.As(err, &guesses.InvalidGuessError{}) {
+					logger.Error("handleEditGuessRules", "err", err)
+					http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+					return
+				}
+				logger.Error("handleEditGuessRules/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			rules, err = gs.GetRules(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleEditGuessRules/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(rules)
+		},
+	)
+}
+
+func handleGetGuessLeaderboard(
+	rs *rooms.RoomService,
+	gs *guesses.GuessService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetGuessLeaderboard", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleGetGuessLeaderboard", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			leaderboard, err := gs.GetLeaderboard(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleGetGuessLeaderboard/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(leaderboard)
+		},
+	)
+}
@@ -7,7 +7,12 @@ import (
 	"github.com/rs/cors"
 	"skafteresort.se/beers/internal/auth"
 	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/guesses"
+	"skafteresort.se/beers/internal/providers"
+	"skafteresort.se/beers/internal/recommendations"
+	"skafteresort.se/beers/internal/results"
 	"skafteresort.se/beers/internal/rooms"
+	"skafteresort.se/beers/internal/stats"
 )
 
 const ContextUserKey = "userID"
@@ -20,7 +25,11 @@ func NewServer(
 	userService *auth.UserService,
 	roomService *rooms.RoomService,
 	beerService *beers.BeerService,
-
+	guessService *guesses.GuessService,
+	resultsService *results.ResultsService,
+	statsService *stats.StatsService,
+	recommendationService *recommendations.RecommendationService,
+	imageProvider *providers.ImageProvider,
 ) http.Handler {
 
 	// panic(allowedOrigins)
@@ -44,7 +53,7 @@ func NewServer(
 		corsMw.Handler(
 			loggingMiddleware(logger,
 				jwtMiddleware(
-					addCentrifugoRoutes(logger, centrifugoHmacKey),
+					addCentrifugoRoutes(logger, centrifugoHmacKey, roomService),
 					jwtSecret,
 					logger,
 				),
@@ -56,7 +65,7 @@ func NewServer(
 		corsMw.Handler(
 			loggingMiddleware(logger,
 				jwtMiddleware(
-					addApiRoutes(logger, userService, roomService, beerService),
+					addApiRoutes(logger, userService, roomService, beerService, guessService, resultsService, statsService, recommendationService, imageProvider),
 					jwtSecret,
 					logger,
 				),
@@ -70,6 +79,7 @@ func NewServer(
 func addCentrifugoRoutes(
 	logger *slog.Logger,
 	centrifugoHmacKey string,
+	roomService *rooms.RoomService,
 ) *http.ServeMux {
 
 	mux := http.NewServeMux()
@@ -81,7 +91,7 @@ func addCentrifugoRoutes(
 
 	mux.Handle(
 		"/broadcasting/auth",
-		handleSubscriptionToken(logger, centrifugoHmacKey),
+		handleSubscriptionToken(roomService, logger, centrifugoHmacKey),
 	)
 
 	return mux
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+func handleGetVoteHistory(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetVoteHistory", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			beerId, err := strconv.Atoi(r.PathValue("beer"))
+			if err != nil {
+				logger.Error("handleGetVoteHistory", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); !ok || err != nil {
+				logger.Error("handleGetVoteHistory", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if ok, err := rs.CheckIfBeerInRoom(r.Context(), roomId, beerId); !ok || err != nil {
+				logger.Error("handleGetVoteHistory", "err", err)
+				http.Error(w, "Beer not found", http.StatusNotFound)
+				return
+			}
+
+			// The history holds every score, so hosts who only see counts
+			// have to wait for the ratings to be published.
+			if ok, err := bs.CanSeeAllRatings(r.Context(), beerId, true); !ok || err != nil {
+				logger.Error("handleGetVoteHistory/visibility", "err", err)
+				http.Error(w, "Ratings are not published yet", http.StatusForbidden)
+				return
+			}
+
+			history, err := bs.GetVoteHistory(r.Context(), beerId, roomId)
+			if err != nil {
+				logger.Error("handleGetVoteHistory/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(history)
+		},
+	)
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"skafteresort.se/beers/internal/rooms"
+)
+
+func handleGetJoinRequests(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetJoinRequests/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageMembers); !ok || err != nil {
+				logger.Error("handleGetJoinRequests", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			requests, err := rs.GetPendingJoinRequests(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleGetJoinRequests/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(requests)
+		},
+	)
+}
+
+func handleApproveJoinRequest(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+) http.Handler {
+	return handleResolveJoinRequest(rs.ApproveJoinRequest, rs, logger, "handleApproveJoinRequest")
+}
+
+func handleRejectJoinRequest(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+) http.Handler {
+	return handleResolveJoinRequest(rs.RejectJoinRequest, rs, logger, "handleRejectJoinRequest")
+}
+
+func handleResolveJoinRequest(
+	resolve func(ctx context.Context, roomId int, requestId int, adminId int) error,
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+	name string,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error(name+"/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			requestId, err := strconv.Atoi(r.PathValue("request"))
+			if err != nil {
+				logger.Error(name+"/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageMembers); !ok || err != nil {
+				logger.Error(name, "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			err = resolve(r.Context(), roomId, requestId, userId.(int))
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					logger.Error(name, "err", "Join request not found")
+					http.Error(w, "Join request not found", http.StatusNotFound)
+					return
+				}
+				logger.Error(name+"/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode("Success")
+		},
+	)
+}
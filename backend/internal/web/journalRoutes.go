@@ -0,0 +1,114 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"skafteresort.se/beers/internal/beers"
+)
+
+func handleGetJournal(
+	bs *beers.BeerService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+
+			filter, err := parseJournalFilter(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			journal, err := bs.GetBeersByUserVotes(r.Context(), userId.(int), filter)
+			if err != nil {
+				if errors.As(err, &beers.InvalidQueryError{}) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				logger.Error("handleGetJournal/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(journal)
+		},
+	)
+}
+
+func parseJournalFilter(q url.Values) (beers.JournalFilter, error) {
+	var err error
+	filter := beers.JournalFilter{
+		Style:  q.Get("style"),
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+	}
+
+	switch q.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Asc = true
+	default:
+		return filter, errors.New("Order must be asc or desc")
+	}
+	if v := q.Get("room"); v != "" {
+		if filter.RoomId, err = strconv.Atoi(v); err != nil {
+			return filter, errors.New("Invalid room")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, errors.New("Invalid limit")
+		}
+	}
+	if filter.From, err = parseJournalDate(q.Get("from"), false); err != nil {
+		return filter, errors.New("Invalid from date")
+	}
+	if filter.To, err = parseJournalDate(q.Get("to"), true); err != nil {
+		return filter, errors.New("Invalid to date")
+	}
+	if filter.MinScore, err = parseOptionalFloat(q.Get("minScore")); err != nil {
+		return filter, errors.New("Invalid minScore")
+	}
+	if filter.MaxScore, err = parseOptionalFloat(q.Get("maxScore")); err != nil {
+		return filter, errors.New("Invalid maxScore")
+	}
+	return filter, nil
+}
+
+// parseJournalDate accepts a date or a timestamp. A plain date used as the
+// end of a range includes the whole day.
+func parseJournalDate(s string, end bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func parseOptionalFloat(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
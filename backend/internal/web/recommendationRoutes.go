@@ -0,0 +1,41 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"skafteresort.se/beers/internal/recommendations"
+)
+
+func handleGetRecommendations(
+	recs *recommendations.RecommendationService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+
+			limit := recommendations.DefaultLimit
+			if v := r.URL.Query().Get("limit"); v != "" {
+				var err error
+				limit, err = strconv.Atoi(v)
+				if err != nil || limit < 1 || limit > recommendations.MaxLimit {
+					http.Error(w, "Limit must be between 1 and 50", http.StatusBadRequest)
+					return
+				}
+			}
+
+			list, err := recs.GetRecommendations(r.Context(), userId.(int), limit)
+			if err != nil {
+				logger.Error("handleGetRecommendations/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list)
+		},
+	)
+}
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"skafteresort.se/beers/internal/providers"
+	"skafteresort.se/beers/internal/report"
+	"skafteresort.se/beers/internal/results"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+func handleGetResults(
+	rs *rooms.RoomService,
+	ress *results.ResultsService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetResults", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleGetResults", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// Hosts see through blind samples, but only see unpublished
+			// ratings if the room shows them more than counts.
+			showHidden, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup)
+			if err != nil {
+				logger.Error("handleGetResults", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			showUnpublished, err := rs.CanSeeUnpublishedRatings(r.Context(), roomId, userId.(int))
+			if err != nil {
+				logger.Error("handleGetResults", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			res, err := ress.GetResults(r.Context(), roomId, showHidden, showUnpublished)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					http.Error(w, "Room not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("handleGetResults/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(res)
+		},
+	)
+}
+
+func handleExportRoom(
+	rs *rooms.RoomService,
+	ress *results.ResultsService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleExportRoom", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			format := r.URL.Query().Get("format")
+			if format == "" {
+				format = "csv"
+			}
+			var contentType string
+			switch format {
+			case "csv":
+				contentType = "text/csv; charset=utf-8"
+			case "json":
+				contentType = "application/json"
+			case "xlsx":
+				contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+			default:
+				http.Error(w, "Unknown format", http.StatusBadRequest)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleExportRoom", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			showHidden, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup)
+			if err != nil {
+				logger.Error("handleExportRoom", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			showUnpublished, err := rs.CanSeeUnpublishedRatings(r.Context(), roomId, userId.(int))
+			if err != nil {
+				logger.Error("handleExportRoom", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			export, err := ress.GetExport(r.Context(), roomId, showHidden, showUnpublished)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					http.Error(w, "Room not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("handleExportRoom/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set(
+				"Content-Disposition",
+				fmt.Sprintf(`attachment; filename="room-%d-ratings.%s"`, roomId, format),
+			)
+
+			switch format {
+			case "csv":
+				err = export.WriteCSV(w)
+			case "json":
+				err = export.WriteJSON(w)
+			case "xlsx":
+				err = export.WriteXLSX(w)
+			}
+			// The headers are already sent, so a failure can only be logged.
+			if err != nil {
+				logger.Error("handleExportRoom/write", "err", err)
+			}
+		},
+	)
+}
+
+func handleGetReport(
+	rs *rooms.RoomService,
+	ress *results.ResultsService,
+	images *providers.ImageProvider,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetReport", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			format := r.URL.Query().Get("format")
+			if format == "" {
+				format = "html"
+			}
+			if format != "html" && format != "pdf" {
+				http.Error(w, "Unknown format", http.StatusBadRequest)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleGetReport", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			room, err := rs.GetRoomById(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleGetReport/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if room.State != rooms.StateFinished && room.State != rooms.StateArchived {
+				http.Error(w, "Room is "+string(room.State), http.StatusConflict)
+				return
+			}
+
+			showHidden, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup)
+			if err != nil {
+				logger.Error("handleGetReport", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			showUnpublished, err := rs.CanSeeUnpublishedRatings(r.Context(), roomId, userId.(int))
+			if err != nil {
+				logger.Error("handleGetReport", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			export, err := ress.GetExport(r.Context(), roomId, showHidden, showUnpublished)
+			if err != nil {
+				logger.Error("handleGetReport/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			recap := report.Build(export)
+
+			if format == "pdf" {
+				// Missing pictures are left out of the PDF rather than
+				// failing it.
+				if err := recap.LoadPictures(r.Context(), images); err != nil {
+					logger.Error("handleGetReport/pictures", "err", err)
+				}
+				w.Header().Set("Content-Type", "application/pdf")
+				w.Header().Set(
+					"Content-Disposition",
+					fmt.Sprintf(`attachment; filename="room-%d-recap.pdf"`, roomId),
+				)
+				err = recap.WritePDF(w)
+			} else {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				err = recap.WriteHTML(w)
+			}
+			if err != nil {
+				logger.Error("handleGetReport/write", "err", err)
+			}
+		},
+	)
+}
@@ -0,0 +1,189 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"skafteresort.se/beers/internal/recommendations"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+func handleCreateDeleteRoomToken(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleCreateDeleteRoomToken/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionDeleteRoom); !ok || err != nil {
+				logger.Error("handleCreateDeleteRoomToken", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			token, expiresAt, err := rs.CreateDeleteToken(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleCreateDeleteRoomToken/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"token":     token,
+				"expiresAt": expiresAt,
+			})
+		},
+	)
+}
+
+func handleDeleteRoom(
+	rs *rooms.RoomService,
+	recs *recommendations.RecommendationService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleDeleteRoom/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionDeleteRoom); !ok || err != nil {
+				logger.Error("handleDeleteRoom", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			var data struct {
+				Token string `json:"token"`
+			}
+			err = json.NewDecoder(r.Body).Decode(&data)
+			if err != nil {
+				logger.Error("handleDeleteRoom/decode", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			err = rs.DeleteRoom(r.Context(), roomId, data.Token)
+			if err != nil {
+				if errors.As(err, &rooms.ConfirmationError{}) {
+					logger.Error("handleDeleteRoom", "err", err)
+					http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+					return
+				}
+				if errors.Is(err, sql.ErrNoRows) {
+					logger.Error("handleDeleteRoom/room", "err", "Room not found")
+					http.Error(w, "Room not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("handleDeleteRoom/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			// Drops the deleted beers from the recommendations.
+			if err := recs.RefreshRoom(r.Context(), roomId); err != nil {
+				logger.Error("handleDeleteRoom/recommendations", "err", err)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode("Success")
+		},
+	)
+}
+
+func handleArchiveRoom(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+	archived bool,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleArchiveRoom/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleArchiveRoom", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			err = rs.UpdateArchived(r.Context(), roomId, userId.(int), archived)
+			if err != nil {
+				logger.Error("handleArchiveRoom/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode("Success")
+		},
+	)
+}
+
+func handleCloneRoom(
+	rs *rooms.RoomService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleCloneRoom/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageRoom); !ok || err != nil {
+				logger.Error("handleCloneRoom", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			var opts rooms.CloneOptions
+			err = json.NewDecoder(r.Body).Decode(&opts)
+			if err != nil {
+				logger.Error("handleCloneRoom/decode", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			room, err := rs.CloneRoom(r.Context(), roomId, userId.(int), opts)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					logger.Error("handleCloneRoom/room", "err", "Room not found")
+					http.Error(w, "Room not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("handleCloneRoom/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(room)
+		},
+	)
+}
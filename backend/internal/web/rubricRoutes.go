@@ -0,0 +1,111 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+func handleGetRubric(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetRubric/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleGetRubric", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			rubric, err := bs.GetRubric(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleGetRubric/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(rubric)
+		},
+	)
+}
+
+func handleEditRubric(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleEditRubric/strconv", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageRoom); !ok || err != nil {
+				logger.Error("handleEditRubric", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionEditRoom); !ok || err != nil {
+				logger.Error("handleEditRubric/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			var rubric beers.Rubric
+			err = json.NewDecoder(r.Body).Decode(&rubric)
+			if err != nil {
+				logger.Error("handleEditRubric/decode", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			err = bs.SetRubric(r.Context(), roomId, rubric)
+			if err != nil {
+				if errors.As(err, &beers.InvalidRatingError{}) {
+					logger.Error("handleEditRubric", "err", err)
+					http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+					return
+				}
+				if errors.As(err, &beers.ConflictError{}) {
+					logger.Error("handleEditRubric", "err", err)
+					http.Error(w, err.Error(), http.StatusConflict)
+					return
+				}
+				logger.Error("handleEditRubric/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			rubric, err = bs.GetRubric(r.Context(), roomId)
+			if err != nil {
+				logger.Error("handleEditRubric/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(rubric)
+		},
+	)
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"skafteresort.se/beers/internal/rooms"
+	"skafteresort.se/beers/internal/stats"
+)
+
+func handleGetUserStats(
+	ss *stats.StatsService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+
+			userStats, err := ss.GetUserStats(r.Context(), userId.(int))
+			if err != nil {
+				logger.Error("handleGetUserStats/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(userStats)
+		},
+	)
+}
+
+func handleGetPalateTwins(
+	ss *stats.StatsService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			userId := r.Context().Value(ContextUserKey)
+
+			method, minOverlap, err := parseSimilarityParams(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			twins, err := ss.GetPalateTwins(r.Context(), userId.(int), method, minOverlap)
+			if err != nil {
+				logger.Error("handleGetPalateTwins/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(twins)
+		},
+	)
+}
+
+func handleGetRoomSimilarity(
+	rs *rooms.RoomService,
+	ss *stats.StatsService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetRoomSimilarity", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckIfUserInRoom(r.Context(), roomId, userId.(int)); !ok || err != nil {
+				logger.Error("handleGetRoomSimilarity", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			method, minOverlap, err := parseSimilarityParams(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			showUnpublished, err := rs.CanSeeUnpublishedRatings(r.Context(), roomId, userId.(int))
+			if err != nil {
+				logger.Error("handleGetRoomSimilarity", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			matrix, err := ss.GetRoomSimilarity(r.Context(), roomId, showUnpublished, method, minOverlap)
+			if err != nil {
+				logger.Error("handleGetRoomSimilarity/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(matrix)
+		},
+	)
+}
+
+func parseSimilarityParams(q url.Values) (string, int, error) {
+	method := q.Get("method")
+	if method == "" {
+		method = stats.MethodPearson
+	}
+	if !stats.ValidMethod(method) {
+		return "", 0, errors.New("Method must be pearson or spearman")
+	}
+
+	minOverlap := stats.DefaultMinOverlap
+	if v := q.Get("minOverlap"); v != "" {
+		var err error
+		minOverlap, err = strconv.Atoi(v)
+		if err != nil || minOverlap < stats.MinOverlap {
+			return "", 0, errors.New("minOverlap must be a number of at least 2")
+		}
+	}
+	return method, minOverlap, nil
+}
@@ -0,0 +1,391 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"skafteresort.se/beers/internal/beers"
+	"skafteresort.se/beers/internal/recommendations"
+	"skafteresort.se/beers/internal/rooms"
+)
+
+type votingDeadlineRequest struct {
+	Deadline *time.Time `json:"deadline"`
+}
+
+// writeVotingClosed answers a refused vote with a machine-readable code, so
+// clients can tell a closed round from a passed deadline.
+func writeVotingClosed(w http.ResponseWriter, err beers.VotingClosedError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":  err.Code,
+		"error": err.ErrorInfo,
+	})
+}
+
+// writeVersionConflict answers a save against an outdated version with the
+// current one, so the client can reload before trying again.
+func writeVersionConflict(w http.ResponseWriter, err beers.VersionConflictError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":   err.ErrorInfo,
+		"version": err.Current,
+	})
+}
+
+func voteETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch reads the vote version a client expects from the If-Match
+// header. ok is false without a header, or with "*", which matches any
+// version.
+func parseIfMatch(r *http.Request) (int, bool, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version < 1 {
+		return 0, false, errors.New("invalid If-Match version")
+	}
+	return version, true, nil
+}
+
+func handleSetVotingClosed(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	closed bool,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleSetVotingClosed", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			beerId, err := strconv.Atoi(r.PathValue("beer"))
+			if err != nil {
+				logger.Error("handleSetVotingClosed", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionPublish); !ok || err != nil {
+				logger.Error("handleSetVotingClosed", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionPublish); !ok || err != nil {
+				logger.Error("handleSetVotingClosed/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			if ok, err := rs.CheckIfBeerInRoom(r.Context(), roomId, beerId); !ok || err != nil {
+				logger.Error("handleSetVotingClosed", "err", err)
+				http.Error(w, "Beer not found", http.StatusNotFound)
+				return
+			}
+
+			if closed {
+				err = bs.CloseVoting(r.Context(), beerId, roomId)
+			} else {
+				err = bs.OpenVoting(r.Context(), beerId, roomId)
+			}
+			if err != nil {
+				logger.Error("handleSetVotingClosed/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		},
+	)
+}
+
+func handleSetBeerVotingDeadline(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleSetBeerVotingDeadline", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			beerId, err := strconv.Atoi(r.PathValue("beer"))
+			if err != nil {
+				logger.Error("handleSetBeerVotingDeadline", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionPublish); !ok || err != nil {
+				logger.Error("handleSetBeerVotingDeadline", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionPublish); !ok || err != nil {
+				logger.Error("handleSetBeerVotingDeadline/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			if ok, err := rs.CheckIfBeerInRoom(r.Context(), roomId, beerId); !ok || err != nil {
+				logger.Error("handleSetBeerVotingDeadline", "err", err)
+				http.Error(w, "Beer not found", http.StatusNotFound)
+				return
+			}
+
+			var req votingDeadlineRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				logger.Error("handleSetBeerVotingDeadline/decode", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			if err := bs.SetVotingDeadline(r.Context(), beerId, roomId, req.Deadline); err != nil {
+				logger.Error("handleSetBeerVotingDeadline/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(req)
+		},
+	)
+}
+
+func handleSetRoomVotingDeadline(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleSetRoomVotingDeadline", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageRoom); !ok || err != nil {
+				logger.Error("handleSetRoomVotingDeadline", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionEditRoom); !ok || err != nil {
+				logger.Error("handleSetRoomVotingDeadline/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			var req votingDeadlineRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				logger.Error("handleSetRoomVotingDeadline/decode", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			if err := rs.UpdateVotingDeadline(r.Context(), roomId, req.Deadline); err != nil {
+				logger.Error("handleSetRoomVotingDeadline/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if err := bs.ReopenAfterRoomDeadline(r.Context(), roomId); err != nil {
+				logger.Error("handleSetRoomVotingDeadline/reopen", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(req)
+		},
+	)
+}
+
+func handleGetVoters(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetVoters", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			beerId, err := strconv.Atoi(r.PathValue("beer"))
+			if err != nil {
+				logger.Error("handleGetVoters", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); !ok || err != nil {
+				logger.Error("handleGetVoters", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if ok, err := rs.CheckIfBeerInRoom(r.Context(), roomId, beerId); !ok || err != nil {
+				logger.Error("handleGetVoters", "err", err)
+				http.Error(w, "Beer not found", http.StatusNotFound)
+				return
+			}
+
+			voters, err := bs.GetVoters(r.Context(), beerId, roomId)
+			if err != nil {
+				logger.Error("handleGetVoters/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(voters)
+		},
+	)
+}
+
+func handleGetVotingProgress(
+	rs *rooms.RoomService,
+	bs *beers.BeerService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleGetVotingProgress", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			beerId, err := strconv.Atoi(r.PathValue("beer"))
+			if err != nil {
+				logger.Error("handleGetVotingProgress", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionManageLineup); !ok || err != nil {
+				logger.Error("handleGetVotingProgress", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if ok, err := rs.CheckIfBeerInRoom(r.Context(), roomId, beerId); !ok || err != nil {
+				logger.Error("handleGetVotingProgress", "err", err)
+				http.Error(w, "Beer not found", http.StatusNotFound)
+				return
+			}
+
+			progress, err := bs.GetProgress(r.Context(), beerId, roomId)
+			if err != nil {
+				logger.Error("handleGetVotingProgress/db", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(progress)
+		},
+	)
+}
+
+type syncVotesRequest struct {
+	Votes []beers.SyncVote `json:"votes"`
+}
+
+// handleSyncVotes saves the votes a client queued while offline. Each vote
+// gets its own result, so one refused vote does not hold up the rest.
+func handleSyncVotes(
+	bs *beers.BeerService,
+	rs *rooms.RoomService,
+	recs *recommendations.RecommendationService,
+	logger *slog.Logger,
+) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			roomId, err := strconv.Atoi(r.PathValue("room"))
+			if err != nil {
+				logger.Error("handleSyncVotes", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			userId := r.Context().Value(ContextUserKey)
+
+			if ok, err := rs.CheckPermission(r.Context(), roomId, userId.(int), rooms.PermissionRate); !ok || err != nil {
+				logger.Error("handleSyncVotes", "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if state, ok, err := rs.CheckRoomAllows(r.Context(), roomId, userId.(int), rooms.ActionVote); !ok || err != nil {
+				logger.Error("handleSyncVotes/state", "err", err, "state", state)
+				http.Error(w, "Room is "+string(state), http.StatusConflict)
+				return
+			}
+
+			var req syncVotesRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				logger.Error("handleSyncVotes", "err", err)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			if len(req.Votes) > beers.MaxSyncBatch {
+				http.Error(w, "At most "+strconv.Itoa(beers.MaxSyncBatch)+" votes can be synced at once", http.StatusUnprocessableEntity)
+				return
+			}
+
+			results := bs.SyncVotes(r.Context(), roomId, userId.(int), req.Votes)
+
+			refreshed := map[int]bool{}
+			for _, res := range results {
+				if res.Status != beers.SyncApplied || refreshed[res.BeerId] {
+					continue
+				}
+				refreshed[res.BeerId] = true
+				// A failed refresh only leaves recommendations stale until
+				// the beer changes again, so it does not fail the sync.
+				if err := recs.RefreshBeer(r.Context(), res.BeerId); err != nil {
+					logger.Error("handleSyncVotes/recommendations", "err", err)
+				}
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string][]beers.SyncResult{
+				"results": results,
+			})
+		},
+	)
+}
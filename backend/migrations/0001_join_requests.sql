@@ -0,0 +1,16 @@
+ALTER TABLE rooms
+  ADD COLUMN require_approval TINYINT(1) NOT NULL DEFAULT 0;
+
+CREATE TABLE join_requests (
+  id INT NOT NULL AUTO_INCREMENT,
+  room_id INT NOT NULL,
+  user_id INT NOT NULL,
+  status ENUM('pending', 'approved', 'rejected') NOT NULL DEFAULT 'pending',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  resolved_at DATETIME NULL,
+  resolved_by INT NULL,
+  PRIMARY KEY (id),
+  KEY join_requests_room_status (room_id, status),
+  CONSTRAINT join_requests_room FOREIGN KEY (room_id) REFERENCES rooms (id),
+  CONSTRAINT join_requests_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,18 @@
+ALTER TABLE user_room
+  ADD COLUMN role ENUM('owner', 'host', 'taster', 'spectator') NOT NULL DEFAULT 'taster';
+
+UPDATE user_room SET role = 'host' WHERE is_admin = 1;
+
+-- user_room has no join timestamp, so the admin with the lowest user id is
+-- the best guess at who created the room.
+UPDATE user_room
+JOIN (
+  SELECT room_id, MIN(user_id) AS user_id
+  FROM user_room
+  WHERE is_admin = 1
+  GROUP BY room_id
+) owners ON owners.room_id = user_room.room_id AND owners.user_id = user_room.user_id
+SET user_room.role = 'owner';
+
+ALTER TABLE user_room
+  DROP COLUMN is_admin;
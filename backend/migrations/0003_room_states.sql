@@ -0,0 +1,3 @@
+-- Existing rooms keep accepting votes and lineup changes.
+ALTER TABLE rooms
+  ADD COLUMN state ENUM('draft', 'open', 'live', 'finished', 'archived') NOT NULL DEFAULT 'open';
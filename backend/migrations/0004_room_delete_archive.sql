@@ -0,0 +1,6 @@
+ALTER TABLE rooms
+  ADD COLUMN delete_token VARCHAR(64) NULL,
+  ADD COLUMN delete_token_expires_at DATETIME NULL;
+
+ALTER TABLE user_room
+  ADD COLUMN archived TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,21 @@
+ALTER TABLE rooms
+  ADD COLUMN scale_min DECIMAL(5, 2) NOT NULL DEFAULT 0,
+  ADD COLUMN scale_max DECIMAL(5, 2) NOT NULL DEFAULT 5,
+  ADD COLUMN scale_step DECIMAL(5, 2) NOT NULL DEFAULT 1,
+  ADD COLUMN allow_half_points TINYINT(1) NOT NULL DEFAULT 0;
+
+ALTER TABLE votes
+  MODIFY COLUMN points DECIMAL(5, 2) NOT NULL;
+
+CREATE OR REPLACE VIEW beers_votes AS
+  SELECT
+    beers.id,
+    beers.name,
+    beers.style,
+    beers.pictureurl,
+    beers.room_id,
+    beers.published,
+    CAST(AVG(votes.points) AS DECIMAL(5, 2)) AS average
+  FROM beers
+  LEFT JOIN votes ON votes.beer_id = beers.id
+  GROUP BY beers.id;
@@ -0,0 +1,23 @@
+CREATE TABLE room_criteria (
+  id INT NOT NULL AUTO_INCREMENT,
+  room_id INT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  position INT NOT NULL DEFAULT 0,
+  scale_min DECIMAL(5, 2) NOT NULL DEFAULT 0,
+  scale_max DECIMAL(5, 2) NOT NULL DEFAULT 5,
+  scale_step DECIMAL(5, 2) NOT NULL DEFAULT 1,
+  allow_half_points TINYINT(1) NOT NULL DEFAULT 0,
+  weight DECIMAL(6, 3) NOT NULL DEFAULT 1,
+  PRIMARY KEY (id),
+  KEY room_criteria_room (room_id, position),
+  CONSTRAINT room_criteria_room FOREIGN KEY (room_id) REFERENCES rooms (id) ON DELETE CASCADE
+);
+
+CREATE TABLE vote_scores (
+  vote_id INT NOT NULL,
+  criterion_id INT NOT NULL,
+  score DECIMAL(5, 2) NOT NULL,
+  PRIMARY KEY (vote_id, criterion_id),
+  CONSTRAINT vote_scores_vote FOREIGN KEY (vote_id) REFERENCES votes (id) ON DELETE CASCADE,
+  CONSTRAINT vote_scores_criterion FOREIGN KEY (criterion_id) REFERENCES room_criteria (id) ON DELETE CASCADE
+);
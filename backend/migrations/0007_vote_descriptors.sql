@@ -0,0 +1,8 @@
+CREATE TABLE vote_descriptors (
+  vote_id INT NOT NULL,
+  descriptor VARCHAR(64) NOT NULL,
+  intensity TINYINT NULL,
+  PRIMARY KEY (vote_id, descriptor),
+  KEY vote_descriptors_descriptor (descriptor),
+  CONSTRAINT vote_descriptors_vote FOREIGN KEY (vote_id) REFERENCES votes (id) ON DELETE CASCADE
+);
@@ -0,0 +1,25 @@
+ALTER TABLE rooms
+  ADD COLUMN blind_mode TINYINT(1) NOT NULL DEFAULT 0;
+
+ALTER TABLE beers
+  ADD COLUMN revealed TINYINT(1) NOT NULL DEFAULT 0;
+
+CREATE OR REPLACE VIEW beers_votes AS
+  SELECT
+    beers.id,
+    beers.name,
+    beers.style,
+    beers.pictureurl,
+    beers.room_id,
+    beers.published,
+    beers.revealed,
+    (
+      SELECT count(*)
+      FROM beers AS earlier
+      WHERE earlier.room_id = beers.room_id
+      AND earlier.id <= beers.id
+    ) AS sample_number,
+    CAST(AVG(votes.points) AS DECIMAL(5, 2)) AS average
+  FROM beers
+  LEFT JOIN votes ON votes.beer_id = beers.id
+  GROUP BY beers.id;
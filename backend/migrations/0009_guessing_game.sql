@@ -0,0 +1,50 @@
+ALTER TABLE beers
+  ADD COLUMN brewery VARCHAR(255) NULL,
+  ADD COLUMN abv DECIMAL(4, 2) NULL;
+
+CREATE OR REPLACE VIEW beers_votes AS
+  SELECT
+    beers.id,
+    beers.name,
+    beers.style,
+    beers.brewery,
+    beers.abv,
+    beers.pictureurl,
+    beers.room_id,
+    beers.published,
+    beers.revealed,
+    (
+      SELECT count(*)
+      FROM beers AS earlier
+      WHERE earlier.room_id = beers.room_id
+      AND earlier.id <= beers.id
+    ) AS sample_number,
+    CAST(AVG(votes.points) AS DECIMAL(5, 2)) AS average
+  FROM beers
+  LEFT JOIN votes ON votes.beer_id = beers.id
+  GROUP BY beers.id;
+
+CREATE TABLE room_guess_rules (
+  room_id INT NOT NULL,
+  field ENUM('style', 'brewery', 'abv') NOT NULL,
+  mode ENUM('exact', 'fuzzy', 'numeric') NOT NULL,
+  points DECIMAL(6, 2) NOT NULL DEFAULT 1,
+  tolerance DECIMAL(6, 2) NOT NULL DEFAULT 0,
+  PRIMARY KEY (room_id, field),
+  CONSTRAINT room_guess_rules_room FOREIGN KEY (room_id) REFERENCES rooms (id) ON DELETE CASCADE
+);
+
+CREATE TABLE guesses (
+  id INT NOT NULL AUTO_INCREMENT,
+  beer_id INT NOT NULL,
+  user_id INT NOT NULL,
+  field ENUM('style', 'brewery', 'abv') NOT NULL,
+  value VARCHAR(255) NOT NULL,
+  score DECIMAL(6, 2) NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY guesses_beer_user_field (beer_id, user_id, field),
+  CONSTRAINT guesses_beer FOREIGN KEY (beer_id) REFERENCES beers (id) ON DELETE CASCADE,
+  CONSTRAINT guesses_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,11 @@
+ALTER TABLE votes
+  ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD COLUMN updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;
+
+-- Existing votes have no recorded time, so date them to their room.
+UPDATE votes
+JOIN beers ON beers.id = votes.beer_id
+JOIN rooms ON rooms.id = beers.room_id
+SET votes.created_at = rooms.created_at, votes.updated_at = rooms.created_at;
+
+CREATE INDEX votes_user_created ON votes (user_id, created_at);
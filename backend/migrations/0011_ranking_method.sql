@@ -0,0 +1,2 @@
+ALTER TABLE rooms
+  ADD COLUMN ranking_method ENUM('raw', 'zscore', 'rank') NOT NULL DEFAULT 'raw';
@@ -0,0 +1,16 @@
+ALTER TABLE rooms
+  ADD COLUMN freeze_votes_on_publish BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE vote_history (
+  id INT NOT NULL AUTO_INCREMENT,
+  vote_id INT NOT NULL,
+  old_points DECIMAL(5, 2) NULL,
+  new_points DECIMAL(5, 2) NOT NULL,
+  old_note TEXT NULL,
+  new_note TEXT NULL,
+  after_publish BOOLEAN NOT NULL DEFAULT FALSE,
+  changed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY vote_history_vote (vote_id),
+  CONSTRAINT vote_history_vote FOREIGN KEY (vote_id) REFERENCES votes (id) ON DELETE CASCADE
+);
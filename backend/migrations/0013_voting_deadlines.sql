@@ -0,0 +1,36 @@
+ALTER TABLE beers
+  ADD COLUMN voting_closed TINYINT(1) NOT NULL DEFAULT 0,
+  ADD COLUMN voting_deadline DATETIME NULL;
+
+ALTER TABLE rooms
+  ADD COLUMN voting_deadline DATETIME NULL;
+
+-- Publishing now closes voting.
+UPDATE beers SET voting_closed = published;
+
+-- Deadlines are stored in UTC and polled by the server.
+CREATE INDEX beers_voting_deadline ON beers (voting_closed, voting_deadline);
+
+CREATE OR REPLACE VIEW beers_votes AS
+  SELECT
+    beers.id,
+    beers.name,
+    beers.style,
+    beers.brewery,
+    beers.abv,
+    beers.pictureurl,
+    beers.room_id,
+    beers.published,
+    beers.revealed,
+    beers.voting_closed,
+    beers.voting_deadline,
+    (
+      SELECT count(*)
+      FROM beers AS earlier
+      WHERE earlier.room_id = beers.room_id
+      AND earlier.id <= beers.id
+    ) AS sample_number,
+    CAST(AVG(votes.points) AS DECIMAL(5, 2)) AS average
+  FROM beers
+  LEFT JOIN votes ON votes.beer_id = beers.id
+  GROUP BY beers.id;
@@ -0,0 +1,3 @@
+-- What hosts see of other participants' ratings before they are published.
+ALTER TABLE rooms
+  ADD COLUMN host_ratings_view ENUM('all', 'counts') NOT NULL DEFAULT 'all';
@@ -0,0 +1,7 @@
+-- How participants are shown next to their ratings. The salt keeps
+-- pseudonyms stable within a room without letting them be reversed.
+ALTER TABLE rooms
+  ADD COLUMN anonymity ENUM('off', 'pseudonym', 'hidden') NOT NULL DEFAULT 'off',
+  ADD COLUMN pseudonym_salt CHAR(36) NOT NULL DEFAULT '';
+
+UPDATE rooms SET pseudonym_salt = UUID();
@@ -0,0 +1,5 @@
+-- Publish a beer's ratings once everyone has voted, or once the timeout
+-- that starts with the first vote runs out.
+ALTER TABLE rooms
+  ADD COLUMN auto_publish TINYINT(1) NOT NULL DEFAULT 0,
+  ADD COLUMN auto_publish_after INT NOT NULL DEFAULT 0;
@@ -0,0 +1,22 @@
+-- When the current value of a vote was decided. Votes synced from offline
+-- clients carry the client's time, so the last edit wins even when it
+-- arrives first.
+ALTER TABLE votes
+  ADD COLUMN decided_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3);
+
+UPDATE votes SET decided_at = updated_at;
+
+-- Every synced vote is recorded under the client's id, so a retried batch
+-- gets the same answer instead of being applied twice.
+CREATE TABLE vote_syncs (
+  user_id INT NOT NULL,
+  client_id VARCHAR(64) NOT NULL,
+  beer_id INT NOT NULL,
+  status ENUM('applied', 'stale', 'rejected') NOT NULL,
+  code VARCHAR(32) NOT NULL DEFAULT '',
+  error VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, client_id),
+  CONSTRAINT vote_syncs_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  CONSTRAINT vote_syncs_beer FOREIGN KEY (beer_id) REFERENCES beers (id) ON DELETE CASCADE
+);
@@ -0,0 +1,16 @@
+-- A user has one vote per beer. Duplicates left by clients that omitted the
+-- vote id are resolved by keeping the vote decided last; their scores,
+-- descriptors and history go with them.
+DELETE older
+FROM votes older
+JOIN votes newer
+  ON newer.beer_id = older.beer_id
+  AND newer.user_id = older.user_id
+  AND (newer.decided_at > older.decided_at
+    OR (newer.decided_at = older.decided_at AND newer.id > older.id));
+
+-- version counts the saves of a vote, for clients that only want to
+-- overwrite the version they last saw.
+ALTER TABLE votes
+  ADD COLUMN version INT NOT NULL DEFAULT 1,
+  ADD UNIQUE KEY votes_beer_user (beer_id, user_id);
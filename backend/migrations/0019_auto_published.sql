@@ -0,0 +1,4 @@
+-- Beers are only auto-published once. A host who unpublishes an
+-- auto-published beer keeps it unpublished after its deadline passes.
+ALTER TABLE beers
+  ADD COLUMN auto_published TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+-- Pseudonyms are stored once assigned, so a participant keeps their name
+-- when someone who draws the same one joins later.
+CREATE TABLE room_pseudonyms (
+  room_id INT NOT NULL,
+  user_id INT NOT NULL,
+  pseudonym VARCHAR(64) NOT NULL,
+  PRIMARY KEY (room_id, user_id),
+  UNIQUE KEY room_pseudonyms_name (room_id, pseudonym),
+  CONSTRAINT room_pseudonyms_room FOREIGN KEY (room_id) REFERENCES rooms (id) ON DELETE CASCADE,
+  CONSTRAINT room_pseudonyms_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,14 @@
+-- Why voting on a beer was closed, so that moving a deadline or
+-- unpublishing only reopens beers closed for that reason, and never a
+-- beer a host closed by hand.
+ALTER TABLE beers
+  ADD COLUMN voting_closed_reason ENUM('', 'host', 'deadline', 'publish') NOT NULL DEFAULT '';
+
+UPDATE beers
+JOIN rooms ON rooms.id = beers.room_id
+SET beers.voting_closed_reason = CASE
+  WHEN beers.published THEN 'publish'
+  WHEN beers.voting_deadline <= UTC_TIMESTAMP() OR rooms.voting_deadline <= UTC_TIMESTAMP() THEN 'deadline'
+  ELSE 'host'
+END
+WHERE beers.voting_closed = 1;
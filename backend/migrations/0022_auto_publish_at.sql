@@ -0,0 +1,4 @@
+-- The auto-publish timeout gets its own column, so it no longer takes the
+-- place of the deadline a host sets on the beer.
+ALTER TABLE beers
+  ADD COLUMN auto_publish_at DATETIME NULL;
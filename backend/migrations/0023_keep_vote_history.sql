@@ -0,0 +1,24 @@
+-- A vote's audit trail outlives the vote. History rows record whose vote on
+-- which beer they belong to, and deleting the vote only unlinks them. The
+-- history still goes with its beer, as when a room is deleted.
+ALTER TABLE vote_history
+  ADD COLUMN beer_id INT NULL AFTER vote_id,
+  ADD COLUMN user_id INT NULL AFTER beer_id;
+
+UPDATE vote_history
+JOIN votes ON votes.id = vote_history.vote_id
+SET
+  vote_history.beer_id = votes.beer_id,
+  vote_history.user_id = votes.user_id;
+
+ALTER TABLE vote_history
+  DROP FOREIGN KEY vote_history_vote;
+
+ALTER TABLE vote_history
+  MODIFY vote_id INT NULL,
+  MODIFY beer_id INT NOT NULL,
+  MODIFY user_id INT NOT NULL,
+  ADD KEY vote_history_beer (beer_id),
+  ADD CONSTRAINT vote_history_vote FOREIGN KEY (vote_id) REFERENCES votes (id) ON DELETE SET NULL,
+  ADD CONSTRAINT vote_history_beer FOREIGN KEY (beer_id) REFERENCES beers (id) ON DELETE CASCADE,
+  ADD CONSTRAINT vote_history_user FOREIGN KEY (user_id) REFERENCES users (id);
@@ -0,0 +1,3 @@
+-- Entries for votes without a note stored an empty new note, while the old
+-- note of the same vote was NULL. Missing notes are NULL on both sides now.
+UPDATE vote_history SET new_note = NULL WHERE new_note = '';